@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// number of rows shown on each page of the admin listings
+const adminPageSize = 20
+
+// pagination holds the links to the surrounding pages of a listing. The
+// links keep the rest of the query string, so the filters aren't lost.
+type pagination struct {
+	Page    int
+	Pages   int
+	PrevURL string
+	NextURL string
+}
+
+func newPagination(r *http.Request, page, total, pageSize int) *pagination {
+	p := &pagination{
+		Page:  page,
+		Pages: (total + pageSize - 1) / pageSize,
+	}
+
+	link := func(page int) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(page))
+		u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		return u.String()
+	}
+	if page > 1 {
+		p.PrevURL = link(page - 1)
+	}
+	if page < p.Pages {
+		p.NextURL = link(page + 1)
+	}
+	return p
+}
+
+// pageNumber returns the page requested in the query string, defaulting to
+// the first one.
+func pageNumber(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
+}
+
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	userStats, err := app.users.Stats()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	snippetStats, err := app.snippets.Stats()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "admin.page.tmpl", &templateData{
+		UserStats:    userStats,
+		SnippetStats: snippetStats,
+	})
+}
+
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.PermittedValues("role", models.RoleUser, models.RoleModerator, models.RoleAdmin)
+
+	filter := models.UserFilter{
+		Search: form.Get("q"),
+		Offset: (pageNumber(r) - 1) * adminPageSize,
+		Limit:  adminPageSize,
+	}
+	if form.Valid() {
+		filter.Role = form.Get("role")
+	}
+
+	users, total, err := app.users.List(filter)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "admin-users.page.tmpl", &templateData{
+		Form:       form,
+		Users:      users,
+		Pagination: newPagination(r, pageNumber(r), total, adminPageSize),
+	})
+}
+
+func (app *application) adminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Admins can't lock themselves out.
+	if id == app.authenticatedUser(r).ID {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	suspended := r.PostForm.Get("suspended") == "true"
+	err = app.users.SetSuspended(id, suspended)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if suspended {
+		app.session.Put(r, "flash", "The user has been suspended.")
+	} else {
+		app.session.Put(r, "flash", "The user has been reinstated.")
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.MatchesPattern("user", digitsRX)
+
+	filter := models.SnippetFilter{
+		Search: form.Get("q"),
+		Offset: (pageNumber(r) - 1) * adminPageSize,
+		Limit:  adminPageSize,
+	}
+	if form.Valid() {
+		filter.UserID, _ = strconv.Atoi(form.Get("user"))
+	}
+
+	snippets, total, err := app.snippets.List(filter)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "admin-snippets.page.tmpl", &templateData{
+		Form:       form,
+		Snippets:   snippets,
+		Pagination: newPagination(r, pageNumber(r), total, adminPageSize),
+	})
+}
+
+func (app *application) adminDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	app.adminSnippetAction(w, r, app.snippets.Delete, "The snippet has been deleted.")
+}
+
+func (app *application) adminExpireSnippet(w http.ResponseWriter, r *http.Request) {
+	app.adminSnippetAction(w, r, app.snippets.Expire, "The snippet has been expired.")
+}
+
+// adminSnippetAction runs one of the moderation actions on the snippet whose
+// id is in the URL, then goes back to the snippets listing.
+func (app *application) adminSnippetAction(w http.ResponseWriter, r *http.Request, action func(int) error, flash string) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = action(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", flash)
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAdminAccess(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+	}{
+		{"Anonymous", "", http.StatusFound},
+		{"User", "alice@example.com", http.StatusForbidden},
+		{"Admin", "ada@example.com", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			for _, path := range []string{"/admin", "/admin/users", "/admin/snippets"} {
+				code, _, _ := ts.get(t, path)
+				if code != tt.wantCode {
+					t.Errorf("%s: want %d; got %d", path, tt.wantCode, code)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminUsers(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "ada@example.com")
+
+	code, _, body := ts.get(t, "/admin/users?q=example&role=admin")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("alice@example.com")) {
+		t.Errorf("want the users to be listed")
+	}
+
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Suspend user", "/admin/users/1/suspend", http.StatusSeeOther},
+		{"Suspend self", "/admin/users/3/suspend", http.StatusBadRequest},
+		{"Non-existent user", "/admin/users/99/suspend", http.StatusNotFound},
+		{"Expire snippet", "/admin/snippets/1/expire", http.StatusSeeOther},
+		{"Delete snippet", "/admin/snippets/1/delete", http.StatusSeeOther},
+		{"Non-existent snippet", "/admin/snippets/2/delete", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("suspended", "true")
+			form.Add("csrf_token", csrfToken)
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"dsolerh/snippetbox/pkg/blobs"
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+)
+
+const (
+	// the most files which can be attached to a snippet
+	maxAttachments = 5
+	// the biggest file which can be attached, in bytes
+	maxAttachmentSize = 1 << 20
+	// the longest name an attachment can have, the size of the name column
+	maxAttachmentNameLength = 255
+	// how much of the uploaded files is kept in memory, the rest is written
+	// to temporary files
+	maxUploadMemory = 2 << 20
+	// how often the attachments of deleted snippets are removed
+	attachmentCleanupInterval = time.Hour
+)
+
+// attachmentTypes are the media types of the files which can be attached to
+// a snippet, as sniffed by http.DetectContentType.
+var attachmentTypes = []string{
+	"text/plain", "image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf",
+}
+
+// attachmentURL returns the link downloading an attachment of the snippet.
+func attachmentURL(s *models.Snippet, a *models.Attachment) string {
+	return snippetURL(s) + "/attachments/" + strconv.Itoa(a.ID)
+}
+
+// fileSize describes a number of bytes for humans, e.g. "12.3 KB".
+func fileSize(n int64) string {
+	switch {
+	case n < 1<<10:
+		return plural(int(n), "byte")
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+}
+
+// maxFilesBodySize returns the biggest body of the forms posting the files of
+// a snippet: the attachments plus the content of the files, which can take up
+// to three times its size once URL-encoded, and room for the other fields.
+func (app *application) maxFilesBodySize() int64 {
+	return maxAttachments*maxAttachmentSize + 3*int64(app.cfg.MaxContentSize) + 1<<20
+}
+
+// parseCreateForm parses the form creating a snippet, which is a multipart
+// form when files are attached to the snippet.
+func parseCreateForm(r *http.Request) (*forms.Form, error) {
+	err := r.ParseMultipartForm(maxUploadMemory)
+	if err != nil && err != http.ErrNotMultipart {
+		return nil, err
+	}
+
+	form := forms.New(r.PostForm)
+	if r.MultipartForm != nil {
+		form.Files = r.MultipartForm.File
+	}
+	return form, nil
+}
+
+// validateAttachments checks the files attached in the attachments field of
+// the form. The server can't read encrypted snippets, so it couldn't encrypt
+// their attachments, and burn after reading snippets are gone once read so
+// their attachments couldn't be downloaded.
+func validateAttachments(form *forms.Form) {
+	files := form.Files["attachments"]
+	if len(files) == 0 {
+		return
+	}
+	if form.Get("content_type") == models.ContentTypeEncrypted || form.Get("burn") == "true" {
+		form.Errors.Add("attachments", "Encrypted and burn after reading snippets can't have attachments")
+		return
+	}
+
+	form.MaxFiles("attachments", maxAttachments)
+	form.MaxFileSize("attachments", maxAttachmentSize)
+	form.PermittedFileTypes("attachments", attachmentTypes...)
+	for _, fh := range files {
+		if utf8.RuneCountInString(fh.Filename) > maxAttachmentNameLength {
+			form.Errors.Add("attachments", fmt.Sprintf("The name of a file is too long (maximum is %d characters)", maxAttachmentNameLength))
+		}
+	}
+}
+
+// storeAttachments puts the content of the uploaded files in the blob store
+// and returns the attachments to insert along with the snippet. If one of
+// them can't be stored, the blobs of the others are deleted.
+func (app *application) storeAttachments(files []*multipart.FileHeader) ([]*models.Attachment, error) {
+	attachments := make([]*models.Attachment, 0, len(files))
+	for _, fh := range files {
+		a, err := app.storeAttachment(fh)
+		if err != nil {
+			app.deleteBlobs(attachments)
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+func (app *application) storeAttachment(fh *multipart.FileHeader) (*models.Attachment, error) {
+	// The type is sniffed again, it's what the attachment is served as.
+	ct, err := forms.FileType(fh)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	key, err := app.blobs.Put(file)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Attachment{Name: fh.Filename, ContentType: ct, Size: fh.Size, Key: key}, nil
+}
+
+// deleteBlobs deletes the blobs of the attachments. Errors are only logged,
+// the blobs left behind take some space but can't be reached anymore.
+func (app *application) deleteBlobs(attachments []*models.Attachment) {
+	for _, a := range attachments {
+		if err := app.blobs.Delete(a.Key); err != nil && err != blobs.ErrNotFound {
+			app.errorLog.Print(err)
+		}
+	}
+}
+
+// downloadAttachment sends an attachment of a snippet the viewer can read.
+// Attachments are always downloaded rather than shown, and browsers are told
+// not to second guess their type, so an uploaded HTML or SVG file can't run
+// scripts on the site.
+func (app *application) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	s, err := app.readableSnippet(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	a, err := app.attachments.Get(id)
+	if err == models.ErrNoRecord || (err == nil && a.SnippetID != s.ID) {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	blob, err := app.blobs.Open(a.Key)
+	if err == blobs.ErrNotFound {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	defer blob.Close()
+
+	// The name is quoted, or encoded when it isn't ASCII, by
+	// FormatMediaType, which gives up on names it can't format.
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+
+	// Once the content is being sent the headers are gone, an error can only
+	// be logged.
+	if _, err = io.Copy(w, blob); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// removeOrphanedAttachments deletes the attachments of the snippets which have
+// been deleted, blobs first, and returns how many were removed.
+func (app *application) removeOrphanedAttachments() (int, error) {
+	orphans, err := app.attachments.Orphans()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, a := range orphans {
+		err = app.blobs.Delete(a.Key)
+		if err != nil && err != blobs.ErrNotFound {
+			return i, err
+		}
+		if err = app.attachments.Delete(a.ID); err != nil && err != models.ErrNoRecord {
+			return i, err
+		}
+	}
+	return len(orphans), nil
+}
+
+// cleanupAttachments periodically removes the attachments of the deleted
+// snippets. It is meant to be run in its own goroutine for the lifetime of the
+// server.
+func (app *application) cleanupAttachments(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := app.removeOrphanedAttachments()
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+		if n > 0 {
+			app.infoLog.Printf("Removed %d orphaned attachments", n)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dsolerh/snippetbox/pkg/blobs"
+	"dsolerh/snippetbox/pkg/models/mock"
+)
+
+// blobCount returns how many blobs the test application stores.
+func blobCount(t *testing.T, app *application) int {
+	entries, err := ioutil.ReadDir(app.blobs.(*blobs.FS).Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestCreateSnippetWithAttachments(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	tests := []struct {
+		name      string
+		burn      string
+		files     map[string][]byte
+		wantCode  int
+		wantBlobs int
+	}{
+		{"Config file", "", map[string][]byte{"config.yaml": []byte("pond: old\n")}, http.StatusSeeOther, 1},
+		{"Image named like a text file", "", map[string][]byte{"frog.txt": png}, http.StatusSeeOther, 1},
+		{"HTML", "", map[string][]byte{"page.png": []byte("<html><script>alert(1)</script></html>")}, http.StatusOK, 0},
+		{"Too big", "", map[string][]byte{"big.txt": bytes.Repeat([]byte("a"), maxAttachmentSize+1)}, http.StatusOK, 0},
+		{"Too many", "", map[string][]byte{"1.txt": {'1'}, "2.txt": {'2'}, "3.txt": {'3'}, "4.txt": {'4'}, "5.txt": {'5'}, "6.txt": {'6'}}, http.StatusOK, 0},
+		{"Burn after reading", "true", map[string][]byte{"config.yaml": []byte("pond: old\n")}, http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := blobCount(t, app)
+
+			form := url.Values{}
+			form.Add("title", "Config")
+			form.Add("file_content", "See the attachments")
+			form.Add("expires", "1w")
+			form.Add("visibility", "public")
+			form.Add("burn", tt.burn)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postMultipart(t, "/snippet/create", form, map[string]map[string][]byte{"attachments": tt.files})
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if n := blobCount(t, app) - before; n != tt.wantBlobs {
+				t.Errorf("want %d new blobs; got %d", tt.wantBlobs, n)
+			}
+		})
+	}
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	content := []byte("pond: old\nsilent: true\n")
+	err := ioutil.WriteFile(filepath.Join(app.blobs.(*blobs.FS).Dir, mock.MockBlobKey), content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/s/pond")
+	if !bytes.Contains(body, []byte("<a href='/s/pond/attachments/1'>config.yaml</a> (23 bytes)")) {
+		t.Errorf("want the attachments to be listed on the snippet")
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Attachment", "/s/pond/attachments/1", http.StatusOK},
+		{"Attachment of another snippet", "/s/pond/attachments/2", http.StatusNotFound},
+		{"Missing blob", "/s/unlisted/attachments/2", http.StatusNotFound},
+		{"Non-existent attachment", "/s/pond/attachments/42", http.StatusNotFound},
+		{"Invalid id", "/s/pond/attachments/abc", http.StatusNotFound},
+		{"Private of another user", "/s/private/attachments/1", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	_, headers, body := ts.get(t, "/s/pond/attachments/1")
+	if !bytes.Equal(body, content) {
+		t.Errorf("want body %q; got %q", content, body)
+	}
+	for header, want := range map[string]string{
+		"Content-Type":           "text/plain; charset=utf-8",
+		"Content-Disposition":    "attachment; filename=config.yaml",
+		"X-Content-Type-Options": "nosniff",
+	} {
+		if got := headers.Get(header); got != want {
+			t.Errorf("want %s %q; got %q", header, want, got)
+		}
+	}
+}
+
+func TestRemoveOrphanedAttachments(t *testing.T) {
+	app := newTestApplication(t)
+
+	// The blob of the mocked orphan, the other one must stay.
+	dir := app.blobs.(*blobs.FS).Dir
+	for _, key := range []string{"00000000000000000000000000000000", mock.MockBlobKey} {
+		if err := ioutil.WriteFile(filepath.Join(dir, key), []byte("blob"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := app.removeOrphanedAttachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 attachment removed; got %d", n)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "00000000000000000000000000000000")); !os.IsNotExist(err) {
+		t.Errorf("want the blob of the orphan to be deleted")
+	}
+	if blobCount(t, app) != 1 {
+		t.Errorf("want the other blobs to be kept")
+	}
+
+	// The blob is already gone the second time, which isn't an error.
+	if _, err = app.removeOrphanedAttachments(); err != nil {
+		t.Errorf("want no error once the blob is gone; got %v", err)
+	}
+}
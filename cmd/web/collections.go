@@ -0,0 +1,307 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// collectionURL returns the link to a collection, which is built from its slug.
+func collectionURL(c *models.Collection) string {
+	return "/c/" + c.Slug
+}
+
+// ownCollection returns the collection from the URL if it belongs to the
+// viewer. Anybody else's collection looks like it doesn't exist.
+func (app *application) ownCollection(r *http.Request) (*models.Collection, error) {
+	c, err := app.collections.GetBySlug(r.URL.Query().Get(":slug"), app.viewerID(r))
+	if err != nil {
+		return nil, err
+	}
+	if c.UserID != app.viewerID(r) {
+		return nil, models.ErrNoRecord
+	}
+	return c, nil
+}
+
+// validateCollection checks the fields of the forms creating and renaming
+// collections.
+func validateCollection(form *forms.Form) {
+	form.Required("name")
+	form.MaxLength("name", 100)
+}
+
+func (app *application) userCollections(w http.ResponseWriter, r *http.Request) {
+	app.renderCollections(w, r, forms.New(nil))
+}
+
+func (app *application) renderCollections(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	collections, err := app.collections.ForUser(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "collections.page.tmpl", &templateData{
+		Collections: collections,
+		Form:        form,
+	})
+}
+
+func (app *application) createCollection(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateCollection(form)
+	if !form.Valid() {
+		app.renderCollections(w, r, form)
+		return
+	}
+
+	c := &models.Collection{
+		UserID: app.authenticatedUser(r).ID,
+		Name:   form.Get("name"),
+		Public: form.Get("public") == "true",
+	}
+	_, err := app.collections.Insert(c)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your collection has been created.")
+	http.Redirect(w, r, collectionURL(c), http.StatusSeeOther)
+}
+
+func (app *application) showCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := app.collections.GetBySlug(r.URL.Query().Get(":slug"), app.viewerID(r))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.renderCollection(w, r, c, forms.New(url.Values{"name": {c.Name}}))
+}
+
+func (app *application) renderCollection(w http.ResponseWriter, r *http.Request, c *models.Collection, form *forms.Form) {
+	snippets, err := app.snippets.InCollection(c.ID, app.viewerID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "collection.page.tmpl", &templateData{
+		Collection: c,
+		Snippets:   snippets,
+		Form:       form,
+	})
+}
+
+func (app *application) renameCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := app.ownCollection(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateCollection(form)
+	if !form.Valid() {
+		app.renderCollection(w, r, c, form)
+		return
+	}
+
+	err = app.collections.Rename(c.ID, c.UserID, form.Get("name"))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your collection has been renamed.")
+	http.Redirect(w, r, collectionURL(c), http.StatusSeeOther)
+}
+
+// shareCollection makes a collection public or private again.
+func (app *application) shareCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := app.ownCollection(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.collections.SetPublic(c.ID, c.UserID, r.PostForm.Get("public") == "true")
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, collectionURL(c), http.StatusSeeOther)
+}
+
+func (app *application) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := app.ownCollection(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.collections.Delete(c.ID, c.UserID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your collection has been deleted, its snippets are still there.")
+	http.Redirect(w, r, "/user/collections", http.StatusSeeOther)
+}
+
+// collectionSnippet returns the id of the snippet in the snippet field of the
+// form, or 0 if it isn't valid.
+func collectionSnippet(r *http.Request) int {
+	id, err := strconv.Atoi(r.PostForm.Get("snippet"))
+	if err != nil || id < 1 {
+		return 0
+	}
+	return id
+}
+
+// removeFromCollection takes a snippet out of a collection. The snippet
+// itself isn't deleted.
+func (app *application) removeFromCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := app.ownCollection(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.collections.RemoveSnippet(c.ID, c.UserID, collectionSnippet(r))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, collectionURL(c), http.StatusSeeOther)
+}
+
+// moveInCollection moves a snippet one place up or down in a collection.
+func (app *application) moveInCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := app.ownCollection(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var delta int
+	switch r.PostForm.Get("direction") {
+	case "up":
+		delta = -1
+	case "down":
+		delta = 1
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.collections.MoveSnippet(c.ID, c.UserID, collectionSnippet(r), delta)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, collectionURL(c), http.StatusSeeOther)
+}
+
+// addToCollection adds the snippet from the URL to one of the viewer's
+// collections.
+func (app *application) addToCollection(w http.ResponseWriter, r *http.Request) {
+	s, err := app.readableSnippet(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	collectionID, err := strconv.Atoi(r.PostForm.Get("collection"))
+	if err != nil || collectionID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.collections.AddSnippet(collectionID, app.authenticatedUser(r).ID, s.ID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "The snippet has been added to your collection.")
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
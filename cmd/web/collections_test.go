@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestShowCollection(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name       string
+		email      string
+		urlPath    string
+		wantCode   int
+		wantManage bool
+	}{
+		{"Public as anonymous", "", "/c/haiku", http.StatusOK, false},
+		{"Public as other user", "ada@example.com", "/c/haiku", http.StatusOK, false},
+		{"Public as owner", "alice@example.com", "/c/haiku", http.StatusOK, true},
+		{"Private as anonymous", "", "/c/drafts", http.StatusNotFound, false},
+		{"Private as other user", "ada@example.com", "/c/drafts", http.StatusNotFound, false},
+		{"Private as owner", "alice@example.com", "/c/drafts", http.StatusOK, true},
+		{"Non-existent", "", "/c/nope", http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if tt.urlPath == "/c/haiku" && !bytes.Contains(body, []byte("An old silent pond")) {
+				t.Errorf("want the snippets of the collection to be listed")
+			}
+			if got := bytes.Contains(body, []byte("Delete collection")); got != tt.wantManage {
+				t.Errorf("want the management forms %v; got %v", tt.wantManage, got)
+			}
+		})
+	}
+}
+
+func TestCreateCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/user/collections")
+	if !bytes.Contains(body, []byte("<a href='/c/drafts'>Drafts</a>")) {
+		t.Errorf("want the user's collections to be listed")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		collection   string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Valid", "Favourites", http.StatusSeeOther, "/c/new"},
+		{"Empty", "", http.StatusOK, ""},
+		{"Too long", string(bytes.Repeat([]byte("a"), 101)), http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.collection)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/user/collections", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := headers.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+}
+
+func TestManageCollection(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		fields   url.Values
+		wantCode int
+	}{
+		{"Rename", "alice@example.com", "/c/haiku/rename", url.Values{"name": {"Poems"}}, http.StatusSeeOther},
+		{"Rename empty", "alice@example.com", "/c/haiku/rename", url.Values{"name": {""}}, http.StatusOK},
+		{"Rename someone else's", "ada@example.com", "/c/haiku/rename", url.Values{"name": {"Mine"}}, http.StatusNotFound},
+		{"Share", "alice@example.com", "/c/drafts/share", url.Values{"public": {"true"}}, http.StatusSeeOther},
+		{"Delete", "alice@example.com", "/c/haiku/delete", nil, http.StatusSeeOther},
+		{"Delete someone else's", "ada@example.com", "/c/haiku/delete", nil, http.StatusNotFound},
+		{"Remove", "alice@example.com", "/c/haiku/remove", url.Values{"snippet": {"1"}}, http.StatusSeeOther},
+		{"Remove missing snippet", "alice@example.com", "/c/haiku/remove", url.Values{"snippet": {"2"}}, http.StatusNotFound},
+		{"Move", "alice@example.com", "/c/haiku/move", url.Values{"snippet": {"1"}, "direction": {"up"}}, http.StatusSeeOther},
+		{"Move sideways", "alice@example.com", "/c/haiku/move", url.Values{"snippet": {"1"}, "direction": {"left"}}, http.StatusBadRequest},
+		{"Add snippet", "alice@example.com", "/s/pond/collect", url.Values{"collection": {"2"}}, http.StatusSeeOther},
+		{"Add to someone else's", "ada@example.com", "/s/pond/collect", url.Values{"collection": {"2"}}, http.StatusNotFound},
+		{"Add burn after reading", "alice@example.com", "/s/burn/collect", url.Values{"collection": {"2"}}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email)
+			_, _, body := ts.get(t, "/user/collections")
+
+			form := url.Values{}
+			for k, v := range tt.fields {
+				form[k] = v
+			}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
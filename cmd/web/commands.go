@@ -0,0 +1,15 @@
+package main
+
+import (
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// promoteToAdmin gives the admin role to the user with the given email. It
+// backs the -promote-admin flag, which is how the first admin is created.
+func promoteToAdmin(users models.IUserModel, email string) error {
+	user, err := users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	return users.SetRole(user.ID, models.RoleAdmin)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+)
+
+const (
+	maxCommentLength = 2000
+	// replies nested deeper than this are shown at the same indentation
+	maxCommentIndent = 5
+)
+
+// commentEntry is a comment as it's displayed: right after the comment it
+// replies to, indented according to its depth in the thread.
+type commentEntry struct {
+	*models.Comment
+	Indent int
+}
+
+// threadComments puts the comments of a snippet, given oldest first, in the
+// order they are displayed. Replies to a comment which no longer exists are
+// shown as new threads.
+func threadComments(comments []*models.Comment) []*commentEntry {
+	ids := map[int]bool{}
+	replies := map[int][]*models.Comment{}
+	roots := []*models.Comment{}
+	for _, c := range comments {
+		ids[c.ID] = true
+		if c.ParentID != 0 && ids[c.ParentID] {
+			replies[c.ParentID] = append(replies[c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	entries := make([]*commentEntry, 0, len(comments))
+	var walk func(c *models.Comment, depth int)
+	walk = func(c *models.Comment, depth int) {
+		indent := depth
+		if indent > maxCommentIndent {
+			indent = maxCommentIndent
+		}
+		entries = append(entries, &commentEntry{Comment: c, Indent: indent})
+		for _, reply := range replies[c.ID] {
+			walk(reply, depth+1)
+		}
+	}
+	for _, c := range roots {
+		walk(c, 0)
+	}
+	return entries
+}
+
+// codeLine is a line of a file of a snippet, numbered from 1 so comments can
+// link to it.
+type codeLine struct {
+	Number int
+	Text   string
+}
+
+func lines(content string) []codeLine {
+	content = strings.TrimSuffix(content, "\n")
+	split := strings.Split(content, "\n")
+	lines := make([]codeLine, len(split))
+	for i, text := range split {
+		lines[i] = codeLine{Number: i + 1, Text: text}
+	}
+	return lines
+}
+
+// commentURL returns the link to a comment on the page of its snippet.
+func commentURL(s *models.Snippet, c *models.Comment) string {
+	return snippetURL(s) + "#comment-" + strconv.Itoa(c.ID)
+}
+
+// ownComment returns the snippet and the comment from the URL if the viewer
+// may comment on the snippet and wrote the comment.
+func (app *application) ownComment(r *http.Request) (*models.Snippet, *models.Comment, error) {
+	s, err := app.readableSnippet(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		return nil, nil, models.ErrNoRecord
+	}
+
+	c, err := app.comments.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.SnippetID != s.ID || c.UserID != app.viewerID(r) || c.Deleted {
+		return nil, nil, models.ErrNoRecord
+	}
+	return s, c, nil
+}
+
+func (app *application) createComment(w http.ResponseWriter, r *http.Request) {
+	s, err := app.readableSnippet(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("content")
+	form.MaxLength("content", maxCommentLength)
+	form.MatchesPattern("parent", digitsRX)
+	form.MatchesPattern("line", digitsRX)
+	form.MatchesPattern("file", digitsRX)
+
+	c := &models.Comment{
+		SnippetID: s.ID,
+		UserID:    app.authenticatedUser(r).ID,
+		Content:   form.Get("content"),
+	}
+
+	// Replies must answer a comment on the same snippet.
+	if parentID, err := strconv.Atoi(form.Get("parent")); err == nil && parentID != 0 {
+		parent, err := app.comments.Get(parentID)
+		if err == models.ErrNoRecord || (err == nil && parent.SnippetID != s.ID) {
+			form.Errors.Add("parent", "The comment you are replying to doesn't exist")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		c.ParentID = parentID
+	}
+
+	// The lines of encrypted snippets are only known in the browser. The
+	// file defaults to the first one, which is the only one most snippets
+	// have.
+	if line, err := strconv.Atoi(form.Get("line")); err == nil && line != 0 {
+		file, _ := strconv.Atoi(form.Get("file"))
+		if s.ContentType == models.ContentTypeEncrypted {
+			form.Errors.Add("line", "The lines of encrypted snippets can't be commented on")
+		} else if f := fileAt(s, file); f == nil {
+			form.Errors.Add("file", "This snippet doesn't have this file")
+		} else if line > len(lines(f.Content)) {
+			form.Errors.Add("line", "This file doesn't have that many lines")
+		}
+		c.File, c.Line = file, line
+	}
+
+	if !form.Valid() {
+		app.renderSnippet(w, r, s, form)
+		return
+	}
+
+	_, err = app.comments.Insert(c)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your comment has been posted.")
+	http.Redirect(w, r, commentURL(s, c), http.StatusSeeOther)
+}
+
+func (app *application) editCommentForm(w http.ResponseWriter, r *http.Request) {
+	s, c, err := app.ownComment(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "comment.page.tmpl", &templateData{
+		Snippet: s,
+		Comment: c,
+		Form:    forms.New(url.Values{"content": {c.Content}}),
+	})
+}
+
+func (app *application) editComment(w http.ResponseWriter, r *http.Request) {
+	s, c, err := app.ownComment(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("content")
+	form.MaxLength("content", maxCommentLength)
+	if !form.Valid() {
+		app.render(w, r, "comment.page.tmpl", &templateData{Snippet: s, Comment: c, Form: form})
+		return
+	}
+
+	err = app.comments.Update(c.ID, c.UserID, form.Get("content"))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your comment has been updated.")
+	http.Redirect(w, r, commentURL(s, c), http.StatusSeeOther)
+}
+
+func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
+	s, c, err := app.ownComment(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.comments.Delete(c.ID, c.UserID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your comment has been deleted.")
+	http.Redirect(w, r, commentURL(s, c), http.StatusSeeOther)
+}
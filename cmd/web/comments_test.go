@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+func TestThreadComments(t *testing.T) {
+	comments := []*models.Comment{
+		{ID: 1},
+		{ID: 2, ParentID: 1},
+		{ID: 3},
+		{ID: 4, ParentID: 2},
+		{ID: 5, ParentID: 1},
+		// the comment it replies to no longer exists
+		{ID: 6, ParentID: 42},
+	}
+
+	got := [][2]int{}
+	for _, e := range threadComments(comments) {
+		got = append(got, [2]int{e.ID, e.Indent})
+	}
+
+	want := [][2]int{{1, 0}, {2, 1}, {4, 2}, {5, 1}, {3, 0}, {6, 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
+
+func TestLines(t *testing.T) {
+	got := lines("first\nsecond\n")
+	want := []codeLine{{1, "first"}, {2, "second"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
+
+func TestShowComments(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/s/pond")
+	for _, want := range []string{
+		"<span class='line' id='f0-L1'>An old silent pond...</span>",
+		"<div class='comment' id='comment-2' style='margin-left: 1em'>",
+		"on <a href='#f0-L1'>line 1 of pond.txt</a>",
+		"Log in</a> to comment",
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestCreateComment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/s/pond")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		content      string
+		parent       string
+		file         string
+		line         string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Valid", "/s/pond/comments", "Nice", "", "", "", http.StatusSeeOther, "/s/pond#comment-3"},
+		{"Reply on a line", "/s/pond/comments", "Nice", "1", "", "1", http.StatusSeeOther, "/s/pond#comment-3"},
+		{"Empty", "/s/pond/comments", "", "", "", "", http.StatusOK, ""},
+		{"Unknown parent", "/s/pond/comments", "Nice", "42", "", "", http.StatusOK, ""},
+		{"Line out of range", "/s/pond/comments", "Nice", "", "", "2", http.StatusOK, ""},
+		{"Line of the second file", "/s/pond/comments", "Nice", "", "1", "2", http.StatusSeeOther, "/s/pond#comment-3"},
+		{"Unknown file", "/s/pond/comments", "Nice", "", "2", "1", http.StatusOK, ""},
+		{"Line of an encrypted snippet", "/s/encrypted/comments", "Nice", "", "", "1", http.StatusOK, ""},
+		{"Burn after reading", "/s/burn/comments", "Nice", "", "", "", http.StatusNotFound, ""},
+		{"Non-existent snippet", "/s/nope/comments", "Nice", "", "", "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("content", tt.content)
+			form.Add("parent", tt.parent)
+			form.Add("file", tt.file)
+			form.Add("line", tt.line)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := headers.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+}
+
+func TestEditComment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+
+	// Only the author can edit or delete a comment.
+	code, _, body := ts.get(t, "/s/pond/comments/1/edit")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("A lovely haiku</textarea>")) {
+		t.Errorf("want the form to be pre-filled with the comment")
+	}
+	if code, _, _ := ts.get(t, "/s/pond/comments/2/edit"); code != http.StatusNotFound {
+		t.Errorf("want %d for someone else's comment; got %d", http.StatusNotFound, code)
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		content  string
+		wantCode int
+	}{
+		{"Edit", "/s/pond/comments/1/edit", "A lovely haiku indeed", http.StatusSeeOther},
+		{"Edit empty", "/s/pond/comments/1/edit", "", http.StatusOK},
+		{"Edit on another snippet", "/s/unlisted/comments/1/edit", "Hi", http.StatusNotFound},
+		{"Edit someone else's", "/s/pond/comments/2/edit", "Hi", http.StatusNotFound},
+		{"Delete", "/s/pond/comments/1/delete", "", http.StatusSeeOther},
+		{"Delete someone else's", "/s/pond/comments/2/delete", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("content", tt.content)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// exportTimeout is how long an export can take to be downloaded, instead of
+// the WriteTimeout of the server which is far too short for big accounts.
+const exportTimeout = 30 * time.Minute
+
+// encryptedManifestName is the name of the file listing the encrypted
+// snippets left out of the archives.
+const encryptedManifestName = "ENCRYPTED.txt"
+
+// snippetsWriter writes the snippets of an export one after the other.
+type snippetsWriter interface {
+	Write(*models.Snippet) error
+	// Close finishes the export, it must be called once every snippet has
+	// been written.
+	Close() error
+}
+
+// exportFormat is one of the formats the snippets can be exported in.
+type exportFormat struct {
+	contentType string
+	extension   string
+	new         func(io.Writer) snippetsWriter
+}
+
+// exportFormats are the formats of the export, by the value of the format
+// parameter. JSON lines hold everything about the snippets, the archives only
+// their files.
+var exportFormats = map[string]exportFormat{
+	"jsonl": {"application/x-ndjson", ".jsonl", newJSONLinesWriter},
+	"zip":   {"application/zip", ".zip", newZIPWriter},
+	"tar":   {"application/gzip", ".tar.gz", newTarWriter},
+}
+
+// exportDir returns the directory of the files of the snippet in the archives,
+// its slug or its id for the snippets which don't have one yet.
+func exportDir(s *models.Snippet) string {
+	if s.Slug != "" {
+		return s.Slug
+	}
+	return strconv.Itoa(s.ID)
+}
+
+// encryptedManifest returns the content of the file listing the encrypted
+// snippets left out of an archive, so they aren't missing without notice.
+func encryptedManifest(snippets []*models.Snippet) string {
+	var b strings.Builder
+	b.WriteString("These snippets are end-to-end encrypted, their files can only be read in the\n")
+	b.WriteString("browser with the key in their link. The JSON lines export has their encrypted\n")
+	b.WriteString("content.\n\n")
+	for _, s := range snippets {
+		fmt.Fprintf(&b, "/s/%s\t%s\n", exportDir(s), s.Title)
+	}
+	return b.String()
+}
+
+// exportTrailer is the last line of the JSON lines exports. An export without
+// it was cut short.
+type exportTrailer struct {
+	Complete bool `json:"complete"`
+	Snippets int  `json:"snippets"`
+}
+
+// jsonLinesWriter writes every snippet as a line of JSON, like exportUserData
+// does for the whole account, and the trailer once they are all written.
+type jsonLinesWriter struct {
+	enc *json.Encoder
+	n   int
+}
+
+func newJSONLinesWriter(w io.Writer) snippetsWriter {
+	return &jsonLinesWriter{enc: json.NewEncoder(w)}
+}
+
+func (jw *jsonLinesWriter) Write(s *models.Snippet) error {
+	jw.n++
+	return jw.enc.Encode(newSnippetExport(s))
+}
+
+func (jw *jsonLinesWriter) Close() error {
+	return jw.enc.Encode(exportTrailer{Complete: true, Snippets: jw.n})
+}
+
+// zipWriter writes the files of every snippet in a directory of their own.
+// The files of encrypted snippets can only be read in the browser, they are
+// left out like they are by downloadSnippet and listed in a file of their own
+// instead.
+type zipWriter struct {
+	zw        *zip.Writer
+	encrypted []*models.Snippet
+}
+
+func newZIPWriter(w io.Writer) snippetsWriter {
+	return &zipWriter{zw: zip.NewWriter(w)}
+}
+
+func (zw *zipWriter) Write(s *models.Snippet) error {
+	if s.ContentType == models.ContentTypeEncrypted {
+		zw.encrypted = append(zw.encrypted, s)
+		return nil
+	}
+
+	for _, f := range s.Files {
+		err := zw.writeFile(path.Join(exportDir(s), path.Base(f.Name)), f.Content, s.Created)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (zw *zipWriter) writeFile(name, content string, modified time.Time) error {
+	fw, err := zw.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified.In(time.UTC),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(fw, content)
+	return err
+}
+
+func (zw *zipWriter) Close() error {
+	if len(zw.encrypted) > 0 {
+		err := zw.writeFile(encryptedManifestName, encryptedManifest(zw.encrypted), time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return zw.zw.Close()
+}
+
+// tarWriter writes the same files as zipWriter in a gzipped tarball.
+type tarWriter struct {
+	gw        *gzip.Writer
+	tw        *tar.Writer
+	encrypted []*models.Snippet
+}
+
+func newTarWriter(w io.Writer) snippetsWriter {
+	gw := gzip.NewWriter(w)
+	return &tarWriter{gw: gw, tw: tar.NewWriter(gw)}
+}
+
+func (tw *tarWriter) Write(s *models.Snippet) error {
+	if s.ContentType == models.ContentTypeEncrypted {
+		tw.encrypted = append(tw.encrypted, s)
+		return nil
+	}
+
+	for _, f := range s.Files {
+		err := tw.writeFile(path.Join(exportDir(s), path.Base(f.Name)), f.Content, s.Created)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tw *tarWriter) writeFile(name, content string, modified time.Time) error {
+	err := tw.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  modified.In(time.UTC),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(tw.tw, content)
+	return err
+}
+
+func (tw *tarWriter) Close() error {
+	if len(tw.encrypted) > 0 {
+		err := tw.writeFile(encryptedManifestName, encryptedManifest(tw.encrypted), time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.tw.Close(); err != nil {
+		return err
+	}
+	return tw.gw.Close()
+}
+
+// exportSnippets streams every snippet of the user in the format asked for,
+// JSON lines by default. The snippets are read a few at a time and flushed as
+// they are written, past the buffer of the session, so the export is never
+// held in memory. It has exportTimeout to be downloaded.
+func (app *application) exportSnippets(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "jsonl"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "snippets"+format.extension))
+
+	sw := format.new(w)
+	flusher, _ := w.(http.Flusher)
+	written := 0
+	err := app.snippets.EachForUser(app.authenticatedUser(r).ID, func(s *models.Snippet) error {
+		if err := sw.Write(s); err != nil {
+			return err
+		}
+		written++
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = sw.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	// Once some of the export is sent the headers are gone, the response is
+	// aborted so the download fails instead of looking complete.
+	if written == 0 {
+		app.serverError(w, err)
+		return
+	}
+	app.errorLog.Print(err)
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExportSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/user/export/snippets")
+	if code != http.StatusFound {
+		t.Errorf("want anonymous users to be redirected; got %d", code)
+	}
+
+	ts.login(t, "alice@example.com")
+
+	if code, _, _ = ts.get(t, "/user/export/snippets?format=rar"); code != http.StatusBadRequest {
+		t.Errorf("want %d for an unknown format; got %d", http.StatusBadRequest, code)
+	}
+
+	wantFiles := map[string]string{
+		"pond/pond.txt":         "An old silent pond...",
+		"pond/frog.txt":         "A frog jumps into the pond,\nsplash! Silence again.",
+		"private/private.txt":   "Only Alice can read this...",
+		"unlisted/unlisted.txt": "Only those with the link...",
+	}
+
+	t.Run("JSON lines", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/user/export/snippets")
+		if code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, code)
+		}
+		if cd := headers.Get("Content-Disposition"); cd != `attachment; filename="snippets.jsonl"` {
+			t.Errorf("want the export to be downloaded; got %q", cd)
+		}
+
+		lines := [][]byte{}
+		sc := bufio.NewScanner(bytes.NewReader(body))
+		for sc.Scan() {
+			lines = append(lines, append([]byte(nil), sc.Bytes()...))
+		}
+		if len(lines) == 0 {
+			t.Fatal("want the export to have lines")
+		}
+
+		slugs := []string{}
+		for _, line := range lines[:len(lines)-1] {
+			var s snippetExport
+			if err := json.Unmarshal(line, &s); err != nil {
+				t.Fatal(err)
+			}
+			slugs = append(slugs, s.Slug)
+		}
+		if strings.Join(slugs, ",") != "pond,private,unlisted,encrypted" {
+			t.Errorf("want a line for every snippet of the user; got %v", slugs)
+		}
+
+		var trailer exportTrailer
+		if err := json.Unmarshal(lines[len(lines)-1], &trailer); err != nil {
+			t.Fatal(err)
+		}
+		if !trailer.Complete || trailer.Snippets != 4 {
+			t.Errorf("want the export to end with its trailer; got %+v", trailer)
+		}
+	})
+
+	t.Run("ZIP", func(t *testing.T) {
+		_, _, body := ts.get(t, "/user/export/snippets?format=zip")
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[f.Name] = string(content)
+		}
+		checkExportedFiles(t, files, wantFiles)
+	})
+
+	t.Run("Tarball", func(t *testing.T) {
+		_, headers, body := ts.get(t, "/user/export/snippets?format=tar")
+		if ct := headers.Get("Content-Type"); ct != "application/gzip" {
+			t.Errorf("want content type %q; got %q", "application/gzip", ct)
+		}
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(gr)
+
+		files := map[string]string{}
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[hdr.Name] = string(content)
+		}
+		checkExportedFiles(t, files, wantFiles)
+	})
+}
+
+// checkExportedFiles compares the files of an archive with the files of the
+// snippets, and checks the encrypted snippet is listed in the manifest.
+func checkExportedFiles(t *testing.T, got, want map[string]string) {
+	t.Helper()
+
+	if !strings.Contains(got[encryptedManifestName], "/s/encrypted\tAn encrypted haiku") {
+		t.Errorf("want the encrypted snippet to be listed in %s; got %q", encryptedManifestName, got[encryptedManifestName])
+	}
+	delete(got, encryptedManifestName)
+
+	names := []string{}
+	for name := range got {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(got) != len(want) {
+		t.Errorf("want %d files; got %v", len(want), names)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("want %s to contain %q; got %q", name, content, got[name])
+		}
+	}
+}
+
+func TestExportSnippetsAborted(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The snippets of Ada can't all be read, the export must not look
+	// complete.
+	ts.login(t, "ada@example.com")
+	rs, err := ts.Client().Get(ts.URL + "/user/export/snippets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := ioutil.ReadAll(rs.Body)
+	if err == nil {
+		t.Errorf("want the download to fail; got %q", body)
+	}
+	if bytes.Contains(body, []byte(`"complete"`)) {
+		t.Errorf("want no trailer in an aborted export")
+	}
+}
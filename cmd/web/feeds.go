@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// the most bytes of every file shown in the feeds, the rest is only on the
+// site
+const maxFeedFileLength = 4096
+
+// atomFeed is an Atom feed, as described by RFC 4287.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Link      atomLink    `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// rssFeed is an RSS 2.0 feed.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// feed is what both kinds of feeds are made of. Path is the path of the feed
+// without its extension, and Created when it began, which is when it was last
+// updated as long as it's empty.
+type feed struct {
+	Title    string
+	Author   string
+	Path     string
+	Created  time.Time
+	Snippets []*models.Snippet
+}
+
+// siteURL returns the scheme and the host the request was sent to, which the
+// links of the feeds need as they are read outside of the site.
+func siteURL(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
+
+// updated returns when the newest snippet of the feed was created, or
+// when the feed was created while it's empty, so its ETag doesn't change
+// until it does. When snippets are edited isn't recorded, so it isn't when
+// the feed last changed: serveFeed relies on the ETag for that.
+func (f *feed) updated() time.Time {
+	if len(f.Snippets) == 0 {
+		return f.Created.UTC()
+	}
+
+	var updated time.Time
+	for _, s := range f.Snippets {
+		if s.Created.After(updated) {
+			updated = s.Created
+		}
+	}
+	return updated.UTC()
+}
+
+// feedContent returns the content of an entry of the feeds, as HTML. The files
+// are escaped, so the feed readers show them as they are. Nothing is shown of
+// the snippets which can't be read by anyone, like they can't on the site.
+func feedContent(s *models.Snippet) string {
+	switch {
+	case s.ContentType == models.ContentTypeEncrypted:
+		return "<p>This snippet is encrypted, open it with its key to read it.</p>"
+	case s.Protected:
+		return "<p>This snippet is protected by a password.</p>"
+	case s.BurnAfterReading:
+		return "<p>This snippet is deleted once read.</p>"
+	}
+
+	var b strings.Builder
+	for _, f := range s.Files {
+		content := f.Content
+		if len(content) > maxFeedFileLength {
+			// cut on a rune boundary
+			n := maxFeedFileLength
+			for n > 0 && !utf8.RuneStart(content[n]) {
+				n--
+			}
+			content = content[:n] + "…"
+		}
+		fmt.Fprintf(&b, "<p><strong>%s</strong></p><pre>%s</pre>", template.HTMLEscapeString(f.Name), template.HTMLEscapeString(content))
+	}
+	return b.String()
+}
+
+// atom returns the feed as an Atom document.
+func (f *feed) atom(site string) interface{} {
+	doc := &atomFeed{
+		ID:      site + f.Path + ".atom",
+		Title:   f.Title,
+		Updated: f.updated().Format(time.RFC3339),
+		Author:  atomPerson{Name: f.Author},
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: site + f.Path + ".atom"},
+			{Rel: "alternate", Type: "text/html", Href: site + "/"},
+		},
+		Entries: make([]atomEntry, 0, len(f.Snippets)),
+	}
+	for _, s := range f.Snippets {
+		link := site + snippetURL(s)
+		created := s.Created.UTC().Format(time.RFC3339)
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:        link,
+			Title:     s.Title,
+			Published: created,
+			Updated:   created,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: link},
+			Content:   atomContent{Type: "html", Body: feedContent(s)},
+		})
+	}
+	return doc
+}
+
+// rss returns the feed as an RSS document.
+func (f *feed) rss(site string) interface{} {
+	doc := &rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          site + "/",
+			Description:   f.Title + " on Snippetbox",
+			LastBuildDate: f.updated().Format(time.RFC1123Z),
+			Items:         make([]rssItem, 0, len(f.Snippets)),
+		},
+	}
+	for _, s := range f.Snippets {
+		link := site + snippetURL(s)
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       s.Title,
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: true, Value: link},
+			PubDate:     s.Created.UTC().Format(time.RFC1123Z),
+			Description: feedContent(s),
+		})
+	}
+	return doc
+}
+
+// serveFeed sends the feed as Atom or RSS, depending on the extension of the
+// path of the request. Readers polling the feed are told when it hasn't
+// changed with its ETag. There is no Last-Modified date, which would miss the
+// edits of the snippets.
+func (app *application) serveFeed(w http.ResponseWriter, r *http.Request, f *feed) {
+	doc, contentType := f.atom(siteURL(r)), "application/atom+xml; charset=utf-8"
+	if strings.HasSuffix(r.URL.Path, ".rss") {
+		doc, contentType = f.rss(siteURL(r)), "application/rss+xml; charset=utf-8"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(doc); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+// latestFeed is the feed of the latest public snippets, like the home page.
+func (app *application) latestFeed(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.Latest(models.SortNewest)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.serveFeed(w, r, &feed{
+		Title:    "Latest snippets",
+		Author:   "Snippetbox",
+		Path:     "/feed",
+		Created:  time.Unix(0, 0),
+		Snippets: snippets,
+	})
+}
+
+// userFeed is the feed of the latest public snippets of a user. The ids of the
+// users are sequential, so the feed is only found for users with public
+// snippets, not to tell anyone else the names of every account.
+func (app *application) userFeed(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	user, err := app.users.Get(id)
+	if err == models.ErrNoRecord || (err == nil && user.Suspended) {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	snippets, err := app.snippets.LatestForUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(snippets) == 0 {
+		app.notFound(w)
+		return
+	}
+
+	app.serveFeed(w, r, &feed{
+		Title:    "Latest snippets of " + user.Name,
+		Author:   user.Name,
+		Path:     fmt.Sprintf("/users/%d/feed", user.ID),
+		Created:  user.Created,
+		Snippets: snippets,
+	})
+}
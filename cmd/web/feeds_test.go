@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+func TestFeeds(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+	}{
+		{"Latest Atom", "/feed.atom", http.StatusOK, "application/atom+xml; charset=utf-8"},
+		{"Latest RSS", "/feed.rss", http.StatusOK, "application/rss+xml; charset=utf-8"},
+		{"User Atom", "/users/1/feed.atom", http.StatusOK, "application/atom+xml; charset=utf-8"},
+		{"User RSS", "/users/1/feed.rss", http.StatusOK, "application/rss+xml; charset=utf-8"},
+		{"Non-existent user", "/users/42/feed.atom", http.StatusNotFound, ""},
+		// their name isn't told to anyone going through the ids
+		{"User without public snippets", "/users/2/feed.atom", http.StatusNotFound, ""},
+		{"Suspended user", "/users/4/feed.atom", http.StatusNotFound, ""},
+		{"Invalid user id", "/users/abc/feed.atom", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if ct := headers.Get("Content-Type"); tt.wantContentType != "" && ct != tt.wantContentType {
+				t.Errorf("want content type %q; got %q", tt.wantContentType, ct)
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/users/1/feed.atom")
+	var atom atomFeed
+	if err := xml.Unmarshal(body, &atom); err != nil {
+		t.Fatal(err)
+	}
+	if atom.Author.Name != "Alice" || len(atom.Entries) != 1 {
+		t.Fatalf("want the feed of Alice with her snippet; got %q with %d entries", atom.Author.Name, len(atom.Entries))
+	}
+	entry := atom.Entries[0]
+	if !strings.HasSuffix(entry.Link.Href, "/s/pond") || !strings.HasPrefix(entry.Link.Href, "https://") {
+		t.Errorf("want an absolute link to the snippet; got %q", entry.Link.Href)
+	}
+	if entry.Updated == "" || entry.Updated != atom.Updated {
+		t.Errorf("want the feed updated when its newest snippet was created; got %q and %q", atom.Updated, entry.Updated)
+	}
+	if !strings.Contains(entry.Content.Body, "<pre>An old silent pond...</pre>") {
+		t.Errorf("want the content of the files; got %q", entry.Content.Body)
+	}
+
+	_, _, body = ts.get(t, "/feed.rss")
+	var rss rssFeed
+	if err := xml.Unmarshal(body, &rss); err != nil {
+		t.Fatal(err)
+	}
+	if len(rss.Channel.Items) != 2 || rss.Channel.Items[0].Title != "An old silent pond" {
+		t.Errorf("want an item for every latest snippet; got %d", len(rss.Channel.Items))
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, headers, _ := ts.get(t, "/feed.atom")
+	etag, lastModified := headers.Get("ETag"), headers.Get("Last-Modified")
+	if etag == "" || lastModified != "" {
+		t.Fatalf("want an ETag and no Last-Modified date; got %q and %q", etag, lastModified)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{"Same ETag", "If-None-Match", etag, http.StatusNotModified},
+		{"Other ETag", "If-None-Match", `"stale"`, http.StatusOK},
+		// the snippets may have been edited since
+		{"Modified since", "If-Modified-Since", time.Now().UTC().Format(http.TimeFormat), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/feed.atom", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(tt.header, tt.value)
+
+			code, _, _ := ts.do(t, req)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestFeedContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+		notWant string
+	}{
+		{
+			"Escaped",
+			&models.Snippet{ContentType: models.ContentTypeText, Files: []*models.File{{Name: "<b>.html", Content: "<script>alert(1)</script>"}}},
+			"<p><strong>&lt;b&gt;.html</strong></p><pre>&lt;script&gt;alert(1)&lt;/script&gt;</pre>",
+			"<script>",
+		},
+		{
+			"Encrypted",
+			&models.Snippet{ContentType: models.ContentTypeEncrypted, Files: []*models.File{{Content: "c2VjcmV0"}}},
+			"encrypted",
+			"c2VjcmV0",
+		},
+		{
+			"Burn after reading",
+			&models.Snippet{ContentType: models.ContentTypeText, BurnAfterReading: true, Files: []*models.File{{Content: "secret"}}},
+			"deleted once read",
+			"secret",
+		},
+		{
+			"Long",
+			&models.Snippet{ContentType: models.ContentTypeText, Files: []*models.File{{Content: strings.Repeat("é", maxFeedFileLength)}}},
+			"é…</pre>",
+			strings.Repeat("é", maxFeedFileLength/2+1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := feedContent(tt.snippet)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("want %q to contain %q", got, tt.want)
+			}
+			if strings.Contains(got, tt.notWant) {
+				t.Errorf("want %q not to contain %q", got, tt.notWant)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+)
+
+const (
+	// the most files a snippet can have
+	maxSnippetFiles = 20
+	// the longest name a file can have, the size of the name column
+	maxFileNameLength = 100
+)
+
+// languages are the languages the files of a snippet can be written in. The
+// empty one is plain text, the others are used as the language-* classes of
+// the code blocks on the show page.
+var languages = []string{
+	"", "bash", "c", "cpp", "css", "go", "html", "java", "javascript", "json",
+	"markdown", "python", "ruby", "rust", "sql", "typescript", "yaml",
+}
+
+// formFiles returns the files posted in the form. Every file is a file_name,
+// a file_language and a file_content field, which are repeated in the order
+// of the files.
+func formFiles(form *forms.Form) []*models.File {
+	names, langs, contents := form.Values["file_name"], form.Values["file_language"], form.Values["file_content"]
+
+	n := len(contents)
+	if len(names) > n {
+		n = len(names)
+	}
+	files := make([]*models.File, n)
+	for i := range files {
+		files[i] = &models.File{}
+		if i < len(names) {
+			files[i].Name = strings.TrimSpace(names[i])
+		}
+		if i < len(langs) {
+			files[i].Language = langs[i]
+		}
+		if i < len(contents) {
+			files[i].Content = contents[i]
+		}
+	}
+	return files
+}
+
+// fileInputs returns the files to show in the form, with an empty one when
+// none have been posted yet so there's always somewhere to write.
+func fileInputs(form *forms.Form) []*models.File {
+	files := formFiles(form)
+	if len(files) == 0 {
+		return []*models.File{{}}
+	}
+	return files
+}
+
+// filesValues returns the form values holding the files, to pre-fill a form
+// with the files of an existing snippet.
+func filesValues(files []*models.File) url.Values {
+	v := url.Values{}
+	for _, f := range files {
+		v.Add("file_name", f.Name)
+		v.Add("file_language", f.Language)
+		v.Add("file_content", f.Content)
+	}
+	return v
+}
+
+// fileField returns the key of the errors of a field of the i-th file.
+func fileField(i int, field string) string {
+	return fmt.Sprintf("file_%s.%d", field, i)
+}
+
+// validateFiles checks the files posted in the form, adding any problem to
+// the errors of the form, and returns them. Files without a name are named
+// after their position. The content of the files can't be bigger than
+// maxSize bytes together. The content of encrypted files is ciphertext, which
+// must be valid base64.
+func validateFiles(form *forms.Form, encrypted bool, maxSize int) []*models.File {
+	files := formFiles(form)
+	if len(files) == 0 {
+		form.Errors.Add("files", "A snippet needs at least one file")
+		return files
+	}
+	if len(files) > maxSnippetFiles {
+		form.Errors.Add("files", fmt.Sprintf("A snippet can't have more than %d files", maxSnippetFiles))
+		return files
+	}
+	form.MaxBytes("file_content", maxSize)
+
+	seen := map[string]bool{}
+	for i, f := range files {
+		if f.Name == "" {
+			f.Name = fmt.Sprintf("file%d.txt", i+1)
+		}
+
+		// Every file gets a form of its own so the usual checks can be used,
+		// their errors are then added to the main form.
+		ff := forms.New(url.Values{"name": {f.Name}, "language": {f.Language}, "content": {f.Content}})
+		ff.Required("content")
+		ff.MaxLength("name", maxFileNameLength)
+		ff.PermittedValues("language", languages...)
+		if encrypted {
+			ff.Base64("content", minCiphertextBytes, maxSize)
+		}
+		// The names are used as they are in the ZIP archives.
+		if f.Name == "." || f.Name == ".." || strings.ContainsAny(f.Name, `/\`) || !utf8.ValidString(f.Name) {
+			ff.Errors.Add("name", "This field isn't a valid file name")
+		} else if seen[f.Name] {
+			ff.Errors.Add("name", "There is already a file with this name")
+		}
+		seen[f.Name] = true
+
+		for field, messages := range ff.Errors {
+			for _, message := range messages {
+				form.Errors.Add(fileField(i, field), message)
+			}
+		}
+	}
+	return files
+}
+
+// fileAt returns the file of the snippet at the position, or nil if there is
+// none, for the comments about a line of one of its files.
+func fileAt(s *models.Snippet, i int) *models.File {
+	if i < 0 || i >= len(s.Files) {
+		return nil
+	}
+	return s.Files[i]
+}
+
+// downloadSnippet sends all the files of a snippet in a ZIP archive. The
+// files of encrypted snippets can only be read in the browser, so there is
+// nothing to download for them.
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s, err := app.readableSnippet(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if s.ContentType == models.ContentTypeEncrypted {
+		app.notFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", s.Slug+".zip"))
+
+	// Once the archive is being written the headers are gone, an error can
+	// only be logged.
+	zw := zip.NewWriter(w)
+	for _, f := range s.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: s.Created.In(time.UTC),
+		})
+		if err != nil {
+			app.errorLog.Print(err)
+			return
+		}
+		if _, err = fw.Write([]byte(f.Content)); err != nil {
+			app.errorLog.Print(err)
+			return
+		}
+	}
+	if err = zw.Close(); err != nil {
+		app.errorLog.Print(err)
+	}
+}
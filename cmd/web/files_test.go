@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"dsolerh/snippetbox/pkg/forms"
+)
+
+func TestValidateFiles(t *testing.T) {
+	tests := []struct {
+		name       string
+		names      []string
+		contents   []string
+		wantErrors []string
+	}{
+		{"Valid", []string{"main.go", "main_test.go"}, []string{"package main", "package main"}, nil},
+		{"Default names", []string{"", ""}, []string{"a", "b"}, nil},
+		{"No files", nil, nil, []string{"files"}},
+		{"Empty content", []string{"main.go"}, []string{" "}, []string{"file_content.0"}},
+		{"Duplicate names", []string{"a.go", "a.go"}, []string{"a", "b"}, []string{"file_name.1"}},
+		{"Path", []string{"../a.go"}, []string{"a"}, []string{"file_name.0"}},
+		{"Dot dot", []string{".."}, []string{"a"}, []string{"file_name.0"}},
+		{"Long name", []string{strings.Repeat("a", maxFileNameLength+1)}, []string{"a"}, []string{"file_name.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.New(url.Values{"file_name": tt.names, "file_content": tt.contents})
+			files := validateFiles(form, false, 1<<20)
+
+			for _, field := range tt.wantErrors {
+				if form.Errors.Get(field) == "" {
+					t.Errorf("want an error on %s", field)
+				}
+			}
+			if len(tt.wantErrors) == 0 && !form.Valid() {
+				t.Errorf("want no errors; got %v", form.Errors)
+			}
+			if len(files) != len(tt.contents) {
+				t.Errorf("want %d files; got %d", len(tt.contents), len(files))
+			}
+		})
+	}
+
+	form := forms.New(url.Values{"file_name": {"", ""}, "file_content": {"a", "b"}})
+	files := validateFiles(form, false, 1<<20)
+	if files[0].Name != "file1.txt" || files[1].Name != "file2.txt" {
+		t.Errorf("want the files without a name to be named after their position; got %q and %q", files[0].Name, files[1].Name)
+	}
+
+	form = forms.New(url.Values{"file_content": make([]string, maxSnippetFiles+1)})
+	validateFiles(form, false, 1<<20)
+	if form.Errors.Get("files") == "" {
+		t.Errorf("want an error with more than %d files", maxSnippetFiles)
+	}
+
+	// The limit is on the content of all the files together.
+	form = forms.New(url.Values{"file_name": {"a.txt", "b.txt"}, "file_content": {"12345", "67890"}})
+	validateFiles(form, false, 8)
+	if form.Errors.Get("file_content") == "" {
+		t.Errorf("want an error when the files are bigger than the maximum together")
+	}
+}
+
+func TestCreateMultiFileSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		names     []string
+		languages []string
+		contents  []string
+		wantCode  int
+	}{
+		{"Two files", []string{"main.go", "main_test.go"}, []string{"go", "go"}, []string{"package main", "package main"}, http.StatusSeeOther},
+		{"Unknown language", []string{"main.cob"}, []string{"cobol"}, []string{"IDENTIFICATION DIVISION."}, http.StatusOK},
+		{"Duplicate names", []string{"main.go", "main.go"}, []string{"go", "go"}, []string{"a", "b"}, http.StatusOK},
+		{"No files", nil, nil, nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Handler")
+			form["file_name"] = tt.names
+			form["file_language"] = tt.languages
+			form["file_content"] = tt.contents
+			form.Add("expires", "1w")
+			form.Add("visibility", "public")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestShowFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/s/pond")
+	for _, want := range []string{
+		"<strong>pond.txt</strong>",
+		"<strong>frog.txt</strong>",
+		"<span class='line' id='f1-L2'>splash! Silence again.</span>",
+		"<a href='/s/pond/zip'>Download ZIP</a>",
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestDownloadSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Public", "/s/pond/zip", http.StatusOK},
+		{"Encrypted", "/s/encrypted/zip", http.StatusNotFound},
+		{"Burn after reading", "/s/burn/zip", http.StatusNotFound},
+		{"Locked", "/s/protected/zip", http.StatusNotFound},
+		{"Private of another user", "/s/private/zip", http.StatusNotFound},
+		{"Non-existent snippet", "/s/nope/zip", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	_, headers, body := ts.get(t, "/s/pond/zip")
+	if ct := headers.Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("want content type %q; got %q", "application/zip", ct)
+	}
+	if cd := headers.Get("Content-Disposition"); cd != `attachment; filename="pond.zip"` {
+		t.Errorf("want the archive to be named after the slug; got %q", cd)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"pond.txt": "An old silent pond...",
+		"frog.txt": "A frog jumps into the pond,\nsplash! Silence again.",
+	}
+	if len(zr.File) != len(want) {
+		t.Fatalf("want %d files in the archive; got %d", len(want), len(zr.File))
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want[f.Name] {
+			t.Errorf("want %s to contain %q; got %q", f.Name, want[f.Name], content)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// forkable reports whether the viewer may fork the snippet. Forking a burn
+// after reading snippet would need reading it, the server can't read the
+// encrypted ones, and protected snippets have to be unlocked first.
+func (app *application) forkable(r *http.Request, s *models.Snippet) bool {
+	if s.BurnAfterReading || s.ContentType == models.ContentTypeEncrypted {
+		return false
+	}
+	return !s.Protected || app.unlocked(r, s)
+}
+
+// forkParent returns the snippet with the given slug if the viewer may fork
+// it. A snippet which can't be forked looks like it doesn't exist.
+func (app *application) forkParent(r *http.Request, slug string) (*models.Snippet, error) {
+	s, err := app.snippets.GetBySlug(slug, app.viewerID(r))
+	if err != nil {
+		return nil, err
+	}
+	if !app.forkable(r, s) {
+		return nil, models.ErrNoRecord
+	}
+	return s, nil
+}
+
+// forkSnippetForm shows the create form pre-filled from the snippet being
+// forked. The fork is only recorded when the form is posted.
+func (app *application) forkSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s, err := app.forkParent(r, r.URL.Query().Get(":slug"))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := forms.New(filesValues(s.Files))
+	form.Set("title", s.Title)
+	form.Set("visibility", s.Visibility)
+	form.Set("forked_from", s.Slug)
+	app.renderCreateForm(w, r, &templateData{
+		Form:    form,
+		Snippet: s,
+	})
+}
+
+// lineage returns the snippet s was forked from and the forks of s, leaving
+// out the ones the viewer couldn't find by themselves.
+func (app *application) lineage(r *http.Request, s *models.Snippet) (*models.Snippet, []*models.Snippet, error) {
+	var parent *models.Snippet
+	if s.ForkedFrom != 0 {
+		var err error
+		parent, err = app.snippets.GetVisible(s.ForkedFrom, app.viewerID(r))
+		if err != nil && err != models.ErrNoRecord {
+			return nil, nil, err
+		}
+	}
+
+	forks, err := app.snippets.Forks(s.ID, app.viewerID(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	return parent, forks, nil
+}
@@ -1,25 +1,43 @@
 package main
 
 import (
-	"fmt"
+	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"dsolerh/snippetbox/pkg/forms"
 	"dsolerh/snippetbox/pkg/models"
 )
 
+const (
+	// The content of encrypted files is an AES-GCM ciphertext: the 12 bytes
+	// of the nonce followed by the encrypted text and the 16 bytes of the tag,
+	// each file being encrypted on its own.
+	minCiphertextBytes = 12 + 16
+)
+
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	s, err := app.snippets.Latest()
+	sort := models.SortNewest
+	if r.URL.Query().Get("sort") == models.SortPopular {
+		sort = models.SortPopular
+	}
+
+	s, err := app.snippets.Latest(sort)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	app.render(w, r, "home.page.tmpl", &templateData{Snippets: s})
+	app.render(w, r, "home.page.tmpl", &templateData{Snippets: s, Sort: sort})
 }
 
-func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
+// showSnippetByID keeps the old numeric links working. Public snippets are
+// redirected to their slug, nothing else can be found by id since sequential
+// ids are trivial to enumerate. The snippets which don't have a slug yet are
+// shown right away, they were all created before they could be protected.
+func (app *application) showSnippetByID(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
 	if err != nil || id < 1 {
 		app.notFound(w)
@@ -35,50 +53,445 @@ func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.Slug == "" {
+		app.renderSnippet(w, r, s, forms.New(nil))
+		return
+	}
+	http.Redirect(w, r, snippetURL(s), http.StatusMovedPermanently)
+}
+
+func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
+	// The model only returns the snippet if the viewer is allowed to see it,
+	// anything else looks exactly like a snippet which doesn't exist.
+	s, err := app.snippets.GetBySlug(r.URL.Query().Get(":slug"), app.viewerID(r))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if s.Protected && !app.unlocked(r, s) {
+		app.render(w, r, "unlock.page.tmpl", &templateData{
+			Form:    forms.New(nil),
+			Snippet: &models.Snippet{Slug: s.Slug, Title: s.Title, Expires: s.Expires},
+		})
+		return
+	}
+
+	// Burn after reading snippets are only revealed through a POST, so the
+	// link previews of chat apps and other bots can't consume them. Only
+	// what's needed for the confirmation page is handed to the template.
+	if s.BurnAfterReading {
+		app.render(w, r, "burn.page.tmpl", &templateData{
+			Snippet: &models.Snippet{Slug: s.Slug, Title: s.Title, Expires: s.Expires},
+		})
+		return
+	}
+
+	// A reply link pre-fills the comment form with the comment it answers.
+	app.renderSnippet(w, r, s, forms.New(url.Values{"parent": {r.URL.Query().Get("reply")}}))
+}
+
+// renderSnippet shows a snippet along with its attachments, its lineage, its
+// comments and, for authenticated viewers, whether they starred it, whether
+// they can edit it and their collections. The form is the one to post a new
+// comment.
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet, form *forms.Form) {
+	parent, forks, err := app.lineage(r, s)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	comments, err := app.comments.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	attachments, err := app.attachments.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	canEdit, err := app.canEdit(r, s)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	starred := false
+	var collections []*models.Collection
+	if user := app.authenticatedUser(r); user != nil {
+		starred, err = app.stars.Starred(user.ID, s.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		collections, err = app.collections.ForUser(user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.render(w, r, "show.page.tmpl", &templateData{
+		Snippet:     s,
+		Attachments: attachments,
+		Starred:     starred,
+		CanEdit:     canEdit,
+		Collections: collections,
+		Parent:      parent,
+		Forks:       forks,
+		Comments:    threadComments(comments),
+		Form:        form,
+	})
+}
+
+func (app *application) burnSnippet(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get(":slug")
+
+	// A protected snippet must be unlocked before it can be burned.
+	s, err := app.snippets.GetBySlug(slug, app.viewerID(r))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if s.Protected && !app.unlocked(r, s) {
+		http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+		return
+	}
+
+	s, err = app.snippets.Burn(slug, app.viewerID(r))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The snippet no longer exists, make sure no copy of it is kept around.
+	w.Header().Set("Cache-Control", "no-store")
 	app.render(w, r, "show.page.tmpl", &templateData{
 		Snippet: s,
+		Burned:  true,
 	})
 }
 
-func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
+// unlockSnippet checks the password of a protected snippet and remembers in
+// the session that it has been unlocked. Wrong guesses are throttled per
+// snippet, whoever makes them.
+func (app *application) unlockSnippet(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.GetBySlug(r.URL.Query().Get(":slug"), app.viewerID(r))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !s.Protected {
+		http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
+
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
+	td := &templateData{
+		Form:    form,
+		Snippet: &models.Snippet{Slug: s.Slug, Title: s.Title, Expires: s.Expires},
+	}
+
+	if !app.unlockLimiter.Allowed(s.ID) {
+		form.Errors.Add("password", "Too many wrong passwords, try again later")
+		w.WriteHeader(http.StatusTooManyRequests)
+		app.render(w, r, "unlock.page.tmpl", td)
+		return
+	}
+
+	err = app.snippets.CheckPassword(s.ID, form.Get("password"))
+	if err == models.ErrInvalidCredentials {
+		app.unlockLimiter.Fail(s.ID)
+		form.Errors.Add("password", "The password is incorrect")
+		app.render(w, r, "unlock.page.tmpl", td)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, unlockedKey(s), true)
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
+	form, err := parseCreateForm(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.Required("title", "expires", "visibility")
 	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "1", "7", "365")
+	expires := snippetExpiry(form, time.Now().UTC())
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+	// bcrypt ignores anything after the 72th byte
+	form.MaxLength("password", 72)
+	if form.Get("content_type") == "" {
+		form.Set("content_type", models.ContentTypeText)
+	}
+	form.PermittedValues("content_type", models.ContentTypeText, models.ContentTypeEncrypted)
+	files := validateFiles(form, form.Get("content_type") == models.ContentTypeEncrypted, app.cfg.MaxContentSize)
+	validateAttachments(form)
+
+	// What needs the database is only checked once the fields are valid.
+	var parent *models.Snippet
+	var teamID int
+	if form.Valid() {
+		if slug := form.Get("forked_from"); slug != "" {
+			parent, err = app.forkParent(r, slug)
+			if err == models.ErrNoRecord {
+				form.Errors.Add("forked_from", "The snippet you are forking can no longer be forked")
+			} else if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+		teamID, err = app.snippetTeam(r, form)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		err = app.checkQuota(form, app.authenticatedUser(r).ID, 1, snippetSize(files, form.Files["attachments"]))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
 
 	if !form.Valid() {
-		app.render(w, r, "create.page.tmpl", &templateData{
+		app.renderCreateForm(w, r, &templateData{
 			Form: form,
 		})
 		return
 	}
 
-	title := r.PostForm.Get("title")
-	content := r.PostForm.Get("content")
-	expires := r.PostForm.Get("expires")
+	s := &models.Snippet{
+		UserID:           app.authenticatedUser(r).ID,
+		Title:            form.Get("title"),
+		Files:            files,
+		Visibility:       form.Get("visibility"),
+		ContentType:      form.Get("content_type"),
+		BurnAfterReading: form.Get("burn") == "true",
+		Password:         form.Get("password"),
+		Expires:          expires,
+		TeamID:           teamID,
+	}
+	if parent != nil {
+		s.ForkedFrom = parent.ID
+	}
 
-	id, err := app.snippets.Insert(title, content, expires)
+	s.Attachments, err = app.storeAttachments(form.Files["attachments"])
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	_, err = app.snippets.Insert(s)
+	if err != nil {
+		app.deleteBlobs(s.Attachments)
+		app.serverError(w, err)
+		return
+	}
+
 	// store session data
 	app.session.Put(r, "flash", "Snippet created successfully!")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+const (
+	// the snippet never expires
+	expiresNever = "never"
+	// the snippet expires at the time given in the expires_at field, in UTC
+	expiresCustom = "custom"
+	// the format of the datetime-local inputs
+	customExpiryLayout = "2006-01-02T15:04"
+	// how often the expired snippets are deleted
+	snippetCleanupInterval = time.Hour
+)
+
+// expiryOptions maps the choices of the expires field to when a snippet
+// created at the given time expires.
+var expiryOptions = map[string]func(time.Time) time.Time{
+	"10m": func(t time.Time) time.Time { return t.Add(10 * time.Minute) },
+	"1h":  func(t time.Time) time.Time { return t.Add(time.Hour) },
+	"1d":  func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+	"1w":  func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+	"1M":  func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+	"1y":  func(t time.Time) time.Time { return t.AddDate(1, 0, 0) },
+}
+
+// snippetExpiry returns when a snippet created now expires according to the
+// expires field of the form, the zero time meaning never. Any problem with the
+// fields is added to the errors of the form.
+func snippetExpiry(form *forms.Form, now time.Time) time.Time {
+	choice := form.Get("expires")
+	if expiry, ok := expiryOptions[choice]; ok {
+		return expiry(now)
+	}
+
+	switch choice {
+	case "", expiresNever:
+		return time.Time{}
+	case expiresCustom:
+		form.Required("expires_at")
+		if form.Get("expires_at") == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(customExpiryLayout, form.Get("expires_at"))
+		if err != nil {
+			form.Errors.Add("expires_at", "This field must be a date and time")
+		} else if !t.After(now) {
+			form.Errors.Add("expires_at", "This field must be in the future")
+		}
+		return t
+	default:
+		form.Errors.Add("expires", "This field is invalid")
+		return time.Time{}
+	}
+}
+
+// cleanupSnippets periodically deletes the expired snippets, and the contents
+// of their files no other snippet shares. It is meant to be run in its own
+// goroutine for the lifetime of the server.
+func (app *application) cleanupSnippets(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := app.snippets.DeleteExpired()
+		if err != nil {
+			app.errorLog.Print(err)
+			continue
+		}
+		if n > 0 {
+			app.infoLog.Printf("Removed %d expired snippets", n)
+		}
+	}
 }
 
 func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "create.page.tmpl", &templateData{
+	app.renderCreateForm(w, r, &templateData{
 		Form: forms.New(nil),
 	})
 }
 
+// renderCreateForm shows the form creating a snippet, which offers the teams
+// of the viewer as owners of the new snippet.
+func (app *application) renderCreateForm(w http.ResponseWriter, r *http.Request, td *templateData) {
+	teams, err := app.teams.ForUser(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td.Teams = teams
+	app.render(w, r, "create.page.tmpl", td)
+}
+
+func (app *application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s, err := app.editableSnippet(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := forms.New(filesValues(s.Files))
+	form.Set("title", s.Title)
+	app.render(w, r, "edit.page.tmpl", &templateData{
+		Form:    form,
+		Snippet: s,
+	})
+}
+
+// editSnippet changes the title and the files of a snippet. Everything
+// else, like who can see it and when it expires, stays as it was chosen when
+// it was created.
+func (app *application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s, err := app.editableSnippet(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	form.Required("title")
+	form.MaxLength("title", 100)
+	files := validateFiles(form, false, app.cfg.MaxContentSize)
+
+	// The snippet counts towards the quota of its owner, whoever edits it.
+	if form.Valid() {
+		size := snippetSize(files, nil) - snippetSize(s.Files, nil)
+		if err = app.checkQuota(form, s.UserID, 0, size); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &templateData{
+			Form:    form,
+			Snippet: s,
+		})
+		return
+	}
+
+	s.Title, s.Files = form.Get("title"), files
+	err = app.snippets.Update(s, app.viewerID(r))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet updated successfully!")
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+func (app *application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.ForUser(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "snippets.page.tmpl", &templateData{Snippets: s})
+}
+
 func (app *application) downloadHandler(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "./ui/static/file.zip")
 }
@@ -144,24 +557,316 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 		app.render(w, r, "login.page.tmpl", &templateData{Form: form})
 		return
 	}
+	if err == models.ErrSuspended {
+		form.Errors.Add("generic", "Your account has been suspended")
+		app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+		return
+	}
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	app.session.Put(r, "userID", id)
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Users with two-factor authentication enabled still need to provide a
+	// code before they are logged in.
+	if user.TOTPEnabled {
+		app.session.Put(r, "pendingUserID", id)
+		http.Redirect(w, r, "/user/login/totp", http.StatusSeeOther)
+		return
+	}
+
+	err = app.startSession(r, id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
 }
 
 func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
-	// Remove the userID from the session
-	app.session.Remove(r, "userID")
+	// End the server-side session and forget its token
+	err := app.endSession(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
 	app.session.Put(r, "flash", "You've been logged out succesfully!")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+func (app *application) userSettings(w http.ResponseWriter, r *http.Request) {
+	usage, err := app.snippets.Usage(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "settings.page.tmpl", &templateData{
+		Usage: usage,
+		Quota: &app.cfg.Quota,
+	})
+}
+
+// userExport is the archive handed out by exportUserData. It contains
+// everything we store about a user except the password hash.
+type userExport struct {
+	ID          int                `json:"id"`
+	Name        string             `json:"name"`
+	Email       string             `json:"email"`
+	Created     time.Time          `json:"created"`
+	Snippets    []snippetExport    `json:"snippets"`
+	Comments    []commentExport    `json:"comments"`
+	Starred     []int              `json:"starred"`
+	Collections []collectionExport `json:"collections"`
+	Teams       []teamExport       `json:"teams"`
+}
+
+type snippetExport struct {
+	ID         int          `json:"id"`
+	Slug       string       `json:"slug"`
+	Title      string       `json:"title"`
+	Files      []fileExport `json:"files"`
+	Created    time.Time    `json:"created"`
+	Expires    *time.Time   `json:"expires"`
+	Visibility string       `json:"visibility"`
+	Type       string       `json:"content_type"`
+	Burn       bool         `json:"burn_after_reading"`
+	Protected  bool         `json:"password_protected"`
+	ForkedFrom int          `json:"forked_from,omitempty"`
+	TeamID     int          `json:"team_id,omitempty"`
+}
+
+type fileExport struct {
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+	Content  string `json:"content"`
+}
+
+func newSnippetExport(s *models.Snippet) snippetExport {
+	return snippetExport{
+		ID:         s.ID,
+		Slug:       s.Slug,
+		Title:      s.Title,
+		Files:      exportFiles(s.Files),
+		Created:    s.Created,
+		Expires:    exportTime(s.Expires),
+		Visibility: s.Visibility,
+		Type:       s.ContentType,
+		Burn:       s.BurnAfterReading,
+		Protected:  s.Protected,
+		ForkedFrom: s.ForkedFrom,
+		TeamID:     s.TeamID,
+	}
+}
+
+func exportFiles(files []*models.File) []fileExport {
+	export := make([]fileExport, 0, len(files))
+	for _, f := range files {
+		export = append(export, fileExport{Name: f.Name, Language: f.Language, Content: f.Content})
+	}
+	return export
+}
+
+type commentExport struct {
+	ID        int        `json:"id"`
+	SnippetID int        `json:"snippet_id"`
+	ParentID  int        `json:"parent_id,omitempty"`
+	File      int        `json:"file,omitempty"`
+	Line      int        `json:"line,omitempty"`
+	Content   string     `json:"content"`
+	Created   time.Time  `json:"created"`
+	Updated   *time.Time `json:"updated"`
+}
+
+type collectionExport struct {
+	ID       int       `json:"id"`
+	Slug     string    `json:"slug"`
+	Name     string    `json:"name"`
+	Public   bool      `json:"public"`
+	Created  time.Time `json:"created"`
+	Snippets []int     `json:"snippets"`
+}
+
+type teamExport struct {
+	ID   int    `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// exportTime returns nil for the zero time, so it's exported as null.
+func exportTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (app *application) exportUserData(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	snippets, err := app.snippets.ForUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	export := userExport{
+		ID:       user.ID,
+		Name:     user.Name,
+		Email:    user.Email,
+		Created:  user.Created,
+		Snippets: make([]snippetExport, 0, len(snippets)),
+	}
+	for _, s := range snippets {
+		export.Snippets = append(export.Snippets, newSnippetExport(s))
+	}
+
+	comments, err := app.comments.ForUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	export.Comments = make([]commentExport, 0, len(comments))
+	for _, c := range comments {
+		export.Comments = append(export.Comments, commentExport{
+			ID:        c.ID,
+			SnippetID: c.SnippetID,
+			ParentID:  c.ParentID,
+			File:      c.File,
+			Line:      c.Line,
+			Content:   c.Content,
+			Created:   c.Created,
+			Updated:   exportTime(c.Updated),
+		})
+	}
+
+	starred, err := app.snippets.StarredBy(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	export.Starred = make([]int, 0, len(starred))
+	for _, s := range starred {
+		export.Starred = append(export.Starred, s.ID)
+	}
+
+	collections, err := app.collections.ForUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	export.Collections = make([]collectionExport, 0, len(collections))
+	for _, c := range collections {
+		snippets, err := app.snippets.InCollection(c.ID, user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		ids := make([]int, 0, len(snippets))
+		for _, s := range snippets {
+			ids = append(ids, s.ID)
+		}
+		export.Collections = append(export.Collections, collectionExport{
+			ID:       c.ID,
+			Slug:     c.Slug,
+			Name:     c.Name,
+			Public:   c.Public,
+			Created:  c.Created,
+			Snippets: ids,
+		})
+	}
+
+	teams, err := app.teams.ForUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	export.Teams = make([]teamExport, 0, len(teams))
+	for _, t := range teams {
+		role, err := app.teams.Role(t.ID, user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		export.Teams = append(export.Teams, teamExport{
+			ID:   t.ID,
+			Slug: t.Slug,
+			Name: t.Name,
+			Role: role,
+		})
+	}
+
+	js, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="snippetbox-export.json"`)
+	w.Write(js)
+}
+
+func (app *application) deleteUserForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "delete.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) deleteUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("password")
+	if !form.Valid() {
+		app.render(w, r, "delete.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	// Ask for the password again so a hijacked session alone can't be used to
+	// wipe the account.
+	user := app.authenticatedUser(r)
+	id, err := app.users.Authenticate(user.Email, form.Get("password"))
+	if err == models.ErrInvalidCredentials || (err == nil && id != user.ID) {
+		form.Errors.Add("password", "Password is incorrect")
+		app.render(w, r, "delete.page.tmpl", &templateData{Form: form})
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.users.Delete(user.ID)
+	if err == models.ErrLastOwner {
+		app.session.Put(r, "flash", "You are the only owner of a team, make someone else an owner first.")
+		http.Redirect(w, r, "/user/teams", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// the sessions of the user are gone with the account
+	app.session.Remove(r, "sessionToken")
+
+	app.session.Put(r, "flash", "Your account and all your snippets have been deleted.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Ok"))
 }
@@ -2,9 +2,15 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models/mock"
+	"dsolerh/snippetbox/pkg/totp"
 )
 
 func TestPing(t *testing.T) {
@@ -53,13 +59,16 @@ func TestShowSnippet(t *testing.T) {
 		wantCode int
 		wantBody []byte
 	}{
-		{"Valid ID", "/snippet/1", http.StatusOK, []byte("An old silent pond...")},
+		{"Valid slug", "/s/pond", http.StatusOK, []byte("An old silent pond...")},
+		{"Non-existent slug", "/s/nope", http.StatusNotFound, nil},
+		{"Empty slug", "/s/", http.StatusNotFound, nil},
+		{"Trailing slash", "/s/pond/", http.StatusNotFound, nil},
 		{"Non-existent ID", "/snippet/2", http.StatusNotFound, nil},
 		{"Negative ID", "/snippet/-1", http.StatusNotFound, nil},
 		{"Decimal ID", "/snippet/1.23", http.StatusNotFound, nil},
 		{"String ID", "/snippet/foo", http.StatusNotFound, nil},
 		{"Empty ID", "/snippet/", http.StatusNotFound, nil},
-		{"Trailing slash", "/snippet/1/", http.StatusNotFound, nil},
+		{"Trailing slash ID", "/snippet/1/", http.StatusNotFound, nil},
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
@@ -74,6 +83,71 @@ func TestShowSnippet(t *testing.T) {
 	}
 }
 
+func TestShowSnippetByID(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Numeric ids of public snippets redirect to the slug.
+	code, headers, _ := ts.get(t, "/snippet/1")
+	if code != http.StatusMovedPermanently {
+		t.Errorf("want %d; got %d", http.StatusMovedPermanently, code)
+	}
+	if loc := headers.Get("Location"); loc != "/s/pond" {
+		t.Errorf("want location %q; got %q", "/s/pond", loc)
+	}
+
+	// Snippets without a slug yet are shown rather than redirected to an
+	// empty one.
+	code, _, body := ts.get(t, "/snippet/11")
+	if code != http.StatusOK || !bytes.Contains(body, []byte("Before there were slugs")) {
+		t.Errorf("want the snippet without a slug to be shown; got %d", code)
+	}
+
+	// Everything else can only be reached through its slug, even by the
+	// owner.
+	ts.login(t, "alice@example.com")
+	for _, path := range []string{"/snippet/3", "/snippet/4"} {
+		code, _, _ := ts.get(t, path)
+		if code != http.StatusNotFound {
+			t.Errorf("%s: want %d; got %d", path, http.StatusNotFound, code)
+		}
+	}
+}
+
+func TestShowSnippetVisibility(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{"Private as anonymous", "", "/s/private", http.StatusNotFound},
+		{"Private as owner", "alice@example.com", "/s/private", http.StatusOK},
+		{"Private as other user", "ada@example.com", "/s/private", http.StatusNotFound},
+		{"Unlisted as anonymous", "", "/s/unlisted", http.StatusOK},
+		{"Unlisted as other user", "ada@example.com", "/s/unlisted", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			code, _, _ := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
 func TestSignupUser(t *testing.T) {
 	// Create the application struct containing our mocked dependencies and set
 	// up the test server for running and end-to-end test.
@@ -126,3 +200,524 @@ func TestSignupUser(t *testing.T) {
 		})
 	}
 }
+
+func TestExportUserData(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous users are sent to the login page.
+	code, headers, _ := ts.get(t, "/user/export")
+	if code != http.StatusFound || headers.Get("Location") != "/user/login" {
+		t.Errorf("want %d redirect to /user/login; got %d %q", http.StatusFound, code, headers.Get("Location"))
+	}
+
+	ts.login(t, "alice@example.com")
+
+	code, headers, body := ts.get(t, "/user/export")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if ct := headers.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("want content type %q; got %q", "application/json", ct)
+	}
+
+	var export userExport
+	if err := json.Unmarshal(body, &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Email != "alice@example.com" {
+		t.Errorf("want email %q; got %q", "alice@example.com", export.Email)
+	}
+	if len(export.Snippets) != 3 || len(export.Snippets[0].Files) != 2 || export.Snippets[0].Files[0].Content != "An old silent pond..." {
+		t.Errorf("want all the mocked snippets in the export; got %+v", export.Snippets)
+	}
+	if len(export.Comments) != 1 || export.Comments[0].Content != "A lovely haiku" {
+		t.Errorf("want the user's comments in the export; got %+v", export.Comments)
+	}
+	if bytes.Contains(body, []byte("hashed")) {
+		t.Error("want the export to not contain any password hash")
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		email        string
+		password     string
+		wantCode     int
+		wantBody     []byte
+		wantLocation string
+	}{
+		{"Empty password", "ada@example.com", "", http.StatusOK, []byte("This field cannot be blank"), ""},
+		{"Valid submission", "ada@example.com", "validPa$$word", http.StatusSeeOther, []byte(""), "/"},
+		{"Last owner of a team", "alice@example.com", "validPa$$word", http.StatusSeeOther, []byte(""), "/user/teams"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email)
+			_, _, body := ts.get(t, "/user/delete")
+
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			code, headers, body := ts.postForm(t, "/user/delete", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+			if loc := headers.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want to be redirected to %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+}
+
+func TestLoginTOTP(t *testing.T) {
+	app := newTestApplication(t)
+
+	validCode, err := totp.Code(mock.MockTOTPSecret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		code         string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Valid code", validCode, http.StatusSeeOther, "/snippet/create"},
+		{"Recovery code", mock.MockRecoveryCode, http.StatusSeeOther, "/snippet/create"},
+		{"Invalid code", "000000", http.StatusOK, ""},
+		{"Empty code", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			// The password step must send the user to the second step
+			// without logging them in yet.
+			_, _, body := ts.get(t, "/user/login")
+			csrfToken := extractCSRFToken(t, body)
+			form := url.Values{}
+			form.Add("email", "tom@example.com")
+			form.Add("password", "validPa$$word")
+			form.Add("csrf_token", csrfToken)
+			code, headers, _ := ts.postForm(t, "/user/login", form)
+			if code != http.StatusSeeOther || headers.Get("Location") != "/user/login/totp" {
+				t.Fatalf("want %d redirect to /user/login/totp; got %d %q", http.StatusSeeOther, code, headers.Get("Location"))
+			}
+
+			code, _, _ = ts.get(t, "/snippet/create")
+			if code != http.StatusFound {
+				t.Fatalf("want %d before the second step; got %d", http.StatusFound, code)
+			}
+
+			form = url.Values{}
+			form.Add("code", tt.code)
+			form.Add("csrf_token", csrfToken)
+			code, headers, _ = ts.postForm(t, "/user/login/totp", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+		})
+	}
+}
+
+// passwordStep logs in as the user with two-factor authentication enabled,
+// up to the second step, and returns the CSRF token.
+func (ts *testServer) passwordStep(t *testing.T) string {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+	form := url.Values{}
+	form.Add("email", "tom@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", csrfToken)
+	code, headers, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther || headers.Get("Location") != "/user/login/totp" {
+		t.Fatalf("want %d redirect to /user/login/totp; got %d %q", http.StatusSeeOther, code, headers.Get("Location"))
+	}
+	return csrfToken
+}
+
+func TestLoginTOTPReplay(t *testing.T) {
+	app := newTestApplication(t)
+
+	validCode, err := totp.Code(mock.MockTOTPSecret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A code can only be used once.
+	for i, wantCode := range []int{http.StatusSeeOther, http.StatusOK} {
+		ts := newTestServer(t, app.routes())
+		csrfToken := ts.passwordStep(t)
+		code, _, _ := ts.postForm(t, "/user/login/totp", url.Values{"code": {validCode}, "csrf_token": {csrfToken}})
+		if code != wantCode {
+			t.Errorf("want %d for use %d of the code; got %d", wantCode, i+1, code)
+		}
+		ts.Close()
+	}
+}
+
+func TestLoginTOTPAttempts(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.passwordStep(t)
+
+	// The cookie of the session is put back after every attempt, like an
+	// attacker replaying it would, which mustn't reset the count.
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := ts.Client().Jar.Cookies(u)
+	for i := 0; i < maxTOTPAttempts; i++ {
+		ts.Client().Jar.SetCookies(u, cookies)
+		code, _, _ := ts.postForm(t, "/user/login/totp", url.Values{"code": {"000000"}, "csrf_token": {csrfToken}})
+		if code != http.StatusOK {
+			t.Fatalf("want %d for attempt %d; got %d", http.StatusOK, i+1, code)
+		}
+	}
+
+	validCode, err := totp.Code(mock.MockTOTPSecret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar.SetCookies(u, cookies)
+	code, headers, _ := ts.postForm(t, "/user/login/totp", url.Values{"code": {validCode}, "csrf_token": {csrfToken}})
+	if code != http.StatusSeeOther || headers.Get("Location") != "/user/login" {
+		t.Errorf("want the login to start over after %d invalid codes; got %d %q", maxTOTPAttempts, code, headers.Get("Location"))
+	}
+}
+
+func TestLogoutUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+
+	code, _, body := ts.get(t, "/user/sessions")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("This session")) {
+		t.Errorf("want the current session to be listed")
+	}
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ = ts.postForm(t, "/user/logout", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	// The server-side session must be gone, not just the cookie value.
+	sessions, err := app.sessions.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("want no sessions left; got %d", len(sessions))
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Log in from two different clients.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t, "alice@example.com")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	sessions, err := app.sessions.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("want 2 sessions; got %d", len(sessions))
+	}
+
+	_, _, body := ts.get(t, "/user/sessions")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/user/sessions/revoke-others", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	// The other client has been logged out, this one hasn't.
+	code, _, _ = other.get(t, "/snippet/create")
+	if code != http.StatusFound {
+		t.Errorf("want %d for the revoked session; got %d", http.StatusFound, code)
+	}
+	code, _, _ = ts.get(t, "/snippet/create")
+	if code != http.StatusOK {
+		t.Errorf("want %d for the current session; got %d", http.StatusOK, code)
+	}
+}
+
+func TestBurnSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Following the link only shows the confirmation page.
+	code, _, body := ts.get(t, "/s/burn")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if bytes.Contains(body, []byte("This message will self-destruct")) {
+		t.Errorf("want the content to be hidden until it's confirmed")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Burn", "/s/burn/burn", http.StatusOK, []byte("This message will self-destruct")},
+		{"Not burn after reading", "/s/pond/burn", http.StatusNotFound, nil},
+		{"Non-existent slug", "/s/nope/burn", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+			if code == http.StatusOK && headers.Get("Cache-Control") != "no-store" {
+				t.Errorf("want the burned snippet to not be cached")
+			}
+		})
+	}
+}
+
+func TestUnlockSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/s/protected")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if bytes.Contains(body, []byte("Behind a locked door")) {
+		t.Fatalf("want the content to be hidden until it's unlocked")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	unlock := func(password string) int {
+		form := url.Values{}
+		form.Add("password", password)
+		form.Add("csrf_token", csrfToken)
+		code, _, _ := ts.postForm(t, "/s/protected/unlock", form)
+		return code
+	}
+
+	if code := unlock("wrong"); code != http.StatusOK {
+		t.Errorf("want %d for a wrong password; got %d", http.StatusOK, code)
+	}
+	if code := unlock(mock.MockSnippetPassword); code != http.StatusSeeOther {
+		t.Errorf("want %d for the right password; got %d", http.StatusSeeOther, code)
+	}
+
+	// The snippet stays unlocked for the rest of the session.
+	_, _, body = ts.get(t, "/s/protected")
+	if !bytes.Contains(body, []byte("Behind a locked door")) {
+		t.Errorf("want the content once the snippet is unlocked")
+	}
+
+	// Too many wrong guesses lock everyone out, even with the right password.
+	for i := 0; i < maxUnlockAttempts; i++ {
+		unlock("wrong")
+	}
+	if code := unlock(mock.MockSnippetPassword); code != http.StatusTooManyRequests {
+		t.Errorf("want %d once throttled; got %d", http.StatusTooManyRequests, code)
+	}
+}
+
+func TestEncryptedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The ciphertext is only handed to the script, never shown as the content.
+	code, _, body := ts.get(t, "/s/encrypted")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("data-ciphertext='"+mock.MockCiphertext+"'")) {
+		t.Errorf("want the ciphertext in the data attribute")
+	}
+	if bytes.Contains(body, []byte(">"+mock.MockCiphertext+"<")) {
+		t.Errorf("want the ciphertext to not be shown as the content")
+	}
+
+	ts.login(t, "alice@example.com")
+	_, _, body = ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		content  string
+		wantCode int
+	}{
+		{"Valid ciphertext", mock.MockCiphertext, http.StatusSeeOther},
+		{"Not base64", "this is not encrypted!", http.StatusOK},
+		{"Too short", "AAAA", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Secret")
+			form.Add("file_content", tt.content)
+			form.Add("content_type", "encrypted")
+			form.Add("expires", "1w")
+			form.Add("visibility", "unlisted")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestSnippetExpiry(t *testing.T) {
+	now := time.Date(2021, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expires   string
+		expiresAt string
+		want      time.Time
+		wantValid bool
+	}{
+		{"Ten minutes", "10m", "", now.Add(10 * time.Minute), true},
+		{"One month", "1M", "", time.Date(2021, 3, 3, 12, 0, 0, 0, time.UTC), true},
+		{"Never", "never", "", time.Time{}, true},
+		{"Custom", "custom", "2021-02-14T09:30", time.Date(2021, 2, 14, 9, 30, 0, 0, time.UTC), true},
+		{"Custom in the past", "custom", "2021-01-30T09:30", time.Date(2021, 1, 30, 9, 30, 0, 0, time.UTC), false},
+		{"Custom without a date", "custom", "", time.Time{}, false},
+		{"Custom not a date", "custom", "tomorrow", time.Time{}, false},
+		{"Unknown choice", "365", "", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.New(url.Values{"expires": {tt.expires}, "expires_at": {tt.expiresAt}})
+			got := snippetExpiry(form, now)
+			if form.Valid() != tt.wantValid {
+				t.Errorf("want valid %v; got errors %v", tt.wantValid, form.Errors)
+			}
+			if tt.wantValid && !got.Equal(tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestForkSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The lineage is shown both ways.
+	_, _, body := ts.get(t, "/s/fork")
+	if !bytes.Contains(body, []byte("Forked from <a href='/s/pond'>")) {
+		t.Errorf("want the fork to link to its parent")
+	}
+	_, _, body = ts.get(t, "/s/pond")
+	if !bytes.Contains(body, []byte("<a href='/s/fork'>")) {
+		t.Errorf("want the parent to list its forks")
+	}
+
+	ts.login(t, "ada@example.com")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Public", "/s/pond/fork", http.StatusOK},
+		{"Burn after reading", "/s/burn/fork", http.StatusNotFound},
+		{"Encrypted", "/s/encrypted/fork", http.StatusNotFound},
+		{"Locked", "/s/protected/fork", http.StatusNotFound},
+		{"Private of another user", "/s/private/fork", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	_, _, body = ts.get(t, "/s/pond/fork")
+	if !bytes.Contains(body, []byte("<textarea name='file_content'>An old silent pond...</textarea>")) {
+		t.Errorf("want the form to be pre-filled from the parent")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	for parent, wantCode := range map[string]int{"pond": http.StatusSeeOther, "burn": http.StatusOK} {
+		form := url.Values{}
+		form.Add("title", "My pond")
+		form.Add("file_content", "A frog jumps into the pond")
+		form.Add("expires", "1w")
+		form.Add("visibility", "public")
+		form.Add("forked_from", parent)
+		form.Add("csrf_token", csrfToken)
+
+		code, _, _ := ts.postForm(t, "/snippet/create", form)
+		if code != wantCode {
+			t.Errorf("forking %s: want %d; got %d", parent, wantCode, code)
+		}
+	}
+	// The parent is only looked up once the fields are valid.
+	form := url.Values{}
+	form.Add("title", "")
+	form.Add("file_content", "A frog jumps into the pond")
+	form.Add("expires", "1w")
+	form.Add("visibility", "public")
+	form.Add("forked_from", "burn")
+	form.Add("csrf_token", csrfToken)
+
+	_, _, body = ts.postForm(t, "/snippet/create", form)
+	if !bytes.Contains(body, []byte("This field cannot be blank")) || bytes.Contains(body, []byte("can no longer be forked")) {
+		t.Errorf("want the fields to be checked before the parent")
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"dsolerh/snippetbox/pkg/models"
 	"fmt"
 	"net/http"
+	"regexp"
 	"runtime/debug"
 	"time"
 
 	"github.com/justinas/nosurf"
 )
 
+// digitsRX matches the ids accepted in forms and query strings.
+var digitsRX = regexp.MustCompile(`^[0-9]+$`)
+
 func (app *application) serverError(w http.ResponseWriter, err error) {
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace)
@@ -25,6 +29,13 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// unauthorized asks the clients of the API to authenticate with HTTP basic
+// auth.
+func (app *application) unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="snippetbox", charset="UTF-8"`)
+	app.clientError(w, http.StatusUnauthorized)
+}
+
 func (app *application) render(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
 	ts, ok := app.templateCache[name]
 	if !ok {
@@ -62,3 +73,88 @@ func (app *application) authenticatedUser(r *http.Request) *models.User {
 	}
 	return user
 }
+
+// viewerID returns the id of the authenticated user, or 0 for anonymous
+// requests, as expected by the snippet model's visibility checks.
+func (app *application) viewerID(r *http.Request) int {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return 0
+	}
+	return user.ID
+}
+
+// unlocked reports whether the viewer may read a protected snippet, either
+// because they own it or because they gave its password during this session.
+func (app *application) unlocked(r *http.Request, s *models.Snippet) bool {
+	if s.UserID == app.viewerID(r) {
+		return true
+	}
+	return app.session.GetBool(r, unlockedKey(s))
+}
+
+// unlockedKey is the session key remembering that a snippet was unlocked.
+func unlockedKey(s *models.Snippet) string {
+	return "unlocked:" + s.Slug
+}
+
+// readableSnippet returns the snippet from the URL if the viewer can read it
+// on its page, so that it can be commented on or starred. Burn after reading
+// snippets can't be read twice so they are left out, and protected ones must
+// be unlocked.
+func (app *application) readableSnippet(r *http.Request) (*models.Snippet, error) {
+	s, err := app.snippets.GetBySlug(r.URL.Query().Get(":slug"), app.viewerID(r))
+	if err != nil {
+		return nil, err
+	}
+	if s.BurnAfterReading || (s.Protected && !app.unlocked(r, s)) {
+		return nil, models.ErrNoRecord
+	}
+	return s, nil
+}
+
+// canEdit reports whether the viewer may edit the snippet. Team snippets can
+// be edited by every member of the team, whoever created them, and the other
+// ones only by their owner. The snippet model checks it again when updating,
+// this only decides what the viewer is offered.
+func (app *application) canEdit(r *http.Request, s *models.Snippet) (bool, error) {
+	viewerID := app.viewerID(r)
+	if viewerID == 0 {
+		return false, nil
+	}
+	if s.TeamID == 0 {
+		return s.UserID == viewerID, nil
+	}
+
+	_, err := app.teams.Role(s.TeamID, viewerID)
+	if err == models.ErrNoRecord {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// editableSnippet returns the snippet from the URL if the viewer may edit it.
+// The content of end-to-end encrypted snippets can't be changed by the server
+// and burn after reading snippets can't be read, so neither can be edited.
+// Protected snippets must be unlocked first, even by the members of their
+// team, as the edit form shows their content.
+func (app *application) editableSnippet(r *http.Request) (*models.Snippet, error) {
+	s, err := app.snippets.GetBySlug(r.URL.Query().Get(":slug"), app.viewerID(r))
+	if err != nil {
+		return nil, err
+	}
+	if s.ContentType == models.ContentTypeEncrypted || s.BurnAfterReading || (s.Protected && !app.unlocked(r, s)) {
+		return nil, models.ErrNoRecord
+	}
+
+	ok, err := app.canEdit(r, s)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return s, nil
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+)
+
+const (
+	// the biggest file which can be imported, in bytes, and the most bytes
+	// the files of an imported archive can hold together
+	maxImportSize = 10 << 20
+	// the most snippets a single import can create, as many as the default
+	// daily quota as the imported snippets count towards it like the others
+	maxImportItems = 100
+)
+
+// extensionLanguages are the languages of the files imported from a ZIP
+// archive, guessed from their extension.
+var extensionLanguages = map[string]string{
+	".sh": "bash", ".c": "c", ".h": "c", ".cpp": "cpp", ".css": "css", ".go": "go",
+	".html": "html", ".java": "java", ".js": "javascript", ".json": "json",
+	".md": "markdown", ".py": "python", ".rb": "ruby", ".rs": "rust", ".sql": "sql",
+	".ts": "typescript", ".yaml": "yaml", ".yml": "yaml",
+}
+
+// errImportTooBig is returned when the files of an archive hold more than
+// maxImportSize bytes together once decompressed.
+var errImportTooBig = errors.New("the files of the archive are too big")
+
+// importFields are the names of the fields of the items in the errors of the
+// report, for the fields of the form they are checked as.
+var importFields = map[string]string{
+	"file_name.0":     "name",
+	"file_language.0": "language",
+	"file_content":    "content",
+	"file_content.0":  "content",
+	"expires":         "expiry",
+	"expires_at":      "expiry",
+}
+
+// importItem is a snippet to import, a line of a JSON lines file or a file
+// of a ZIP archive. Expiry is one of the choices of the expires field, like
+// "1w", an RFC 3339 time, or empty for never. Snippets have no tags here,
+// items with tags are rejected rather than imported without them.
+type importItem struct {
+	Title    string   `json:"title"`
+	Content  string   `json:"content"`
+	Language string   `json:"language"`
+	Expiry   string   `json:"expiry"`
+	Tags     []string `json:"tags"`
+	// the position of the item in the file, from 1: its line or the
+	// position of its file in the archive
+	pos int
+	// the name of its file, only known in archives
+	name string
+	// why the item couldn't be read, like a line which isn't JSON
+	err string
+}
+
+// importResult is what became of an item: the link to the snippet created
+// from it, or why it can't be imported.
+type importResult struct {
+	Item   int                 `json:"item"`
+	Name   string              `json:"name,omitempty"`
+	Title  string              `json:"title"`
+	URL    string              `json:"url,omitempty"`
+	Errors map[string][]string `json:"errors,omitempty"`
+}
+
+// importReport answers an import. Nothing is imported unless every item can
+// be, Errors being the problems with the upload itself.
+type importReport struct {
+	Imported int                 `json:"imported"`
+	Errors   map[string][]string `json:"errors,omitempty"`
+	Items    []*importResult     `json:"items"`
+}
+
+// readImport reads the items of an uploaded file, a ZIP archive or a JSON
+// lines file depending on its content. It reads one more item than can be
+// imported, so too big files can be told apart.
+func readImport(fh *multipart.FileHeader, maxSize int) ([]*importItem, error) {
+	ct, err := forms.FileType(fh)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if ct == "application/zip" {
+		return readZIPImport(file, fh.Size, maxSize)
+	}
+	return readJSONLinesImport(file)
+}
+
+func readJSONLinesImport(r io.Reader) ([]*importItem, error) {
+	br := bufio.NewReader(r)
+	items := []*importItem{}
+	for line := 1; len(items) <= maxImportItems; line++ {
+		b, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(b)) > 0 {
+			item := &importItem{}
+			if jerr := json.Unmarshal(b, item); jerr != nil {
+				item = &importItem{err: "This line isn't a valid JSON object"}
+			}
+			item.pos = line
+			items = append(items, item)
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// readZIPImport reads every file of the archive as a snippet named after its
+// path, the directories being skipped. Archives can be made of files which
+// take a lot more room once decompressed, it stops with errImportTooBig once
+// more than maxImportSize bytes have been decompressed.
+func readZIPImport(r io.ReaderAt, size int64, maxSize int) ([]*importItem, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := int64(maxImportSize)
+	items := []*importItem{}
+	for i, f := range zr.File {
+		if len(items) > maxImportItems {
+			break
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		item := &importItem{
+			Title:    f.Name,
+			Language: extensionLanguages[strings.ToLower(path.Ext(f.Name))],
+			pos:      i + 1,
+			name:     path.Base(f.Name),
+		}
+		if item.Content, item.err, err = readZIPFile(f, maxSize, &remaining); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// readZIPFile returns the content of a file of an archive, or why it can't be
+// imported. No more than maxSize bytes of it are ever decompressed, nor more
+// than the remaining bytes of the archive, which it takes its size from.
+func readZIPFile(f *zip.File, maxSize int, remaining *int64) (string, string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", "This file can't be read from the archive", nil
+	}
+	defer rc.Close()
+
+	limit := int64(maxSize)
+	if *remaining < limit {
+		limit = *remaining
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return "", "This file can't be read from the archive", nil
+	}
+	if *remaining -= int64(len(b)); *remaining < 0 {
+		return "", "", errImportTooBig
+	}
+	if len(b) > maxSize {
+		return "", fmt.Sprintf("This file is too big (maximum is %d bytes)", maxSize), nil
+	}
+	return string(b), "", nil
+}
+
+// importExpiry sets the expires fields of the form from the expiry of an item.
+func importExpiry(form *forms.Form, expiry string) {
+	if _, ok := expiryOptions[expiry]; ok || expiry == "" || expiry == expiresNever {
+		form.Set("expires", expiry)
+		return
+	}
+
+	t, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		form.Errors.Add("expires", "This field must be like 1w, never or an RFC 3339 time")
+		return
+	}
+	form.Set("expires", expiresCustom)
+	form.Set("expires_at", t.UTC().Format(customExpiryLayout))
+}
+
+// importSnippet checks an item like createSnippet checks what's posted, and
+// returns the snippet to insert or the errors of the item.
+func (app *application) importSnippet(item *importItem, userID int, visibility string, now time.Time) (*models.Snippet, map[string][]string) {
+	if item.err != "" {
+		return nil, map[string][]string{"item": {item.err}}
+	}
+
+	form := forms.New(url.Values{
+		"title":         {item.Title},
+		"file_name":     {item.name},
+		"file_language": {item.Language},
+		"file_content":  {item.Content},
+	})
+	importExpiry(form, item.Expiry)
+	form.Required("title")
+	form.MaxLength("title", 100)
+	expires := snippetExpiry(form, now)
+	files := validateFiles(form, false, app.cfg.MaxContentSize)
+	if !utf8.ValidString(item.Content) {
+		form.Errors.Add(fileField(0, "content"), "This field must be text")
+	}
+	if len(item.Tags) > 0 {
+		form.Errors.Add("tags", "Snippets can't have tags, remove them to import this item")
+	}
+
+	if !form.Valid() {
+		itemErrors := map[string][]string{}
+		for field, messages := range form.Errors {
+			if name, ok := importFields[field]; ok {
+				field = name
+			}
+			itemErrors[field] = append(itemErrors[field], messages...)
+		}
+		return nil, itemErrors
+	}
+
+	return &models.Snippet{
+		UserID:      userID,
+		Title:       item.Title,
+		Files:       files,
+		Visibility:  visibility,
+		ContentType: models.ContentTypeText,
+		Expires:     expires,
+	}, nil
+}
+
+func (app *application) importSnippetsForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "import.page.tmpl", &templateData{
+		Form: forms.New(url.Values{"visibility": {models.VisibilityPrivate}}),
+	})
+}
+
+// importSnippets creates a snippet for every item of the uploaded file, all
+// of them in a single transaction. It serves both the import form and the
+// API for scripts, /api/snippets/import, which answers with the report as
+// JSON. Clients of the form asking for JSON get it too.
+func (app *application) importSnippets(w http.ResponseWriter, r *http.Request) {
+	form, err := parseCreateForm(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if form.Get("visibility") == "" {
+		form.Set("visibility", models.VisibilityPrivate)
+	}
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+	if len(form.Files["file"]) != 1 {
+		form.Errors.Add("file", "Choose a file to import")
+	} else {
+		form.MaxFileSize("file", maxImportSize)
+		form.PermittedFileTypes("file", "application/zip", "text/plain")
+	}
+
+	var items []*importItem
+	if form.Valid() {
+		items, err = readImport(form.Files["file"][0], app.cfg.MaxContentSize)
+		switch {
+		case err == errImportTooBig:
+			form.Errors.Add("file", fmt.Sprintf("The files of this archive are too big together (maximum is %d bytes)", maxImportSize))
+		case err != nil:
+			form.Errors.Add("file", "This file can't be read, it must be a ZIP archive or a JSON lines file")
+		case len(items) == 0:
+			form.Errors.Add("file", "This file has no snippets")
+		case len(items) > maxImportItems:
+			form.Errors.Add("file", fmt.Sprintf("This file has too many snippets (maximum is %d)", maxImportItems))
+		}
+	}
+
+	userID := app.authenticatedUser(r).ID
+	report := &importReport{Items: []*importResult{}}
+	snippets := []*models.Snippet{}
+	if form.Valid() {
+		now := time.Now().UTC()
+		var size int64
+		for _, item := range items {
+			s, itemErrors := app.importSnippet(item, userID, form.Get("visibility"), now)
+			report.Items = append(report.Items, &importResult{Item: item.pos, Name: item.name, Title: item.Title, Errors: itemErrors})
+			if s != nil {
+				snippets = append(snippets, s)
+				size += snippetSize(s.Files, nil)
+			}
+		}
+		if err = app.checkQuota(form, userID, len(items), size); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() || len(snippets) < len(items) {
+		report.Errors = form.Errors
+		app.renderImportReport(w, r, form, report, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err = app.snippets.InsertMany(snippets); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	for i, s := range snippets {
+		report.Items[i].URL = snippetURL(s)
+	}
+	report.Imported = len(snippets)
+	app.renderImportReport(w, r, form, report, http.StatusOK)
+}
+
+// renderImportReport answers an import with its report, as JSON for the API
+// and the clients accepting it, along with the import form otherwise.
+func (app *application) renderImportReport(w http.ResponseWriter, r *http.Request, form *forms.Form, report *importReport, status int) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") && !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		app.render(w, r, "import.page.tmpl", &templateData{
+			Form:   form,
+			Import: report,
+		})
+		return
+	}
+
+	js, err := json.Marshal(report)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// zipArchive returns a ZIP archive of the files, by their name.
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadZIPImport(t *testing.T) {
+	b := zipArchive(t, map[string]string{
+		"cmd/main.go": "package main",
+		"docs/":       "",
+		"big.txt":     strings.Repeat("a", 13),
+	})
+
+	items, err := readZIPImport(bytes.NewReader(b), int64(len(b)), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("want the directories to be skipped; got %d items", len(items))
+	}
+	for _, item := range items {
+		switch item.Title {
+		case "cmd/main.go":
+			if item.name != "main.go" || item.Language != "go" || item.Content != "package main" {
+				t.Errorf("want main.go in go; got %q in %q with %q", item.name, item.Language, item.Content)
+			}
+		case "big.txt":
+			if item.err == "" {
+				t.Errorf("want an error for a file bigger than the maximum")
+			}
+		default:
+			t.Errorf("unexpected item %q", item.Title)
+		}
+	}
+}
+
+func TestReadZIPImportTooBig(t *testing.T) {
+	// Repeated bytes compress well, the archive is small but its files
+	// hold more than can be imported.
+	content := strings.Repeat("a", maxImportSize/2+1)
+	b := zipArchive(t, map[string]string{"a.txt": content, "b.txt": content})
+	if len(b) > maxImportSize/100 {
+		t.Fatalf("want a small archive; got %d bytes", len(b))
+	}
+
+	if _, err := readZIPImport(bytes.NewReader(b), int64(len(b)), maxImportSize); err != errImportTooBig {
+		t.Errorf("want errImportTooBig; got %v", err)
+	}
+}
+
+func TestImportSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/import")
+	csrfToken := extractCSRFToken(t, body)
+
+	lines := `{"title": "Pond", "content": "An old silent pond...", "expiry": "1w"}
+
+{"title": "Frog", "content": "A frog jumps into the pond", "language": "markdown", "expiry": "2099-01-01T00:00:00Z"}
+`
+	tests := []struct {
+		name     string
+		file     map[string][]byte
+		wantBody []string
+	}{
+		{"JSON lines", map[string][]byte{"old.jsonl": []byte(lines)}, []string{"Every snippet was imported", "<a href='/s/new2'>Frog</a>"}},
+		{"ZIP", map[string][]byte{"old.zip": zipArchive(t, map[string]string{"main.go": "package main"})}, []string{"Every snippet was imported", "<a href='/s/new1'>main.go</a>"}},
+		{"Invalid line", map[string][]byte{"old.jsonl": []byte(lines + "{\"title\": \n")}, []string{"Nothing was imported", "item: This line isn&#39;t a valid JSON object"}},
+		{"Invalid item", map[string][]byte{"old.jsonl": []byte(`{"title": "", "content": "x", "language": "cobol", "expiry": "soon"}`)}, []string{"title: This field cannot be blank", "language: This fiel is invalid", "expiry: This field must be like 1w"}},
+		{"Item with tags", map[string][]byte{"old.jsonl": []byte(`{"title": "Pond", "content": "x", "tags": ["haiku"]}`)}, []string{"Nothing was imported", "tags: Snippets can&#39;t have tags"}},
+		{"Empty file", map[string][]byte{"old.jsonl": []byte("\n")}, []string{"This file has no snippets"}},
+		{"Image", map[string][]byte{"old.png": []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")}, []string{"old.png is of a type which isn&#39;t allowed"}},
+		{"No file", nil, []string{"Choose a file to import"}},
+		// alice created 2 snippets today, the daily quota is 10
+		{"Over the daily quota", map[string][]byte{"old.jsonl": []byte(strings.Repeat(`{"title": "Pond", "content": "An old silent pond..."}`+"\n", 9))}, []string{"Nothing was imported", "You can&#39;t create more than 10 snippets a day"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("visibility", "unlisted")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postMultipart(t, "/snippet/import", form, map[string]map[string][]byte{"file": tt.file})
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			for _, want := range tt.wantBody {
+				if !bytes.Contains(body, []byte(want)) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportSnippetsJSON(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/import")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		lines        string
+		wantCode     int
+		wantImported int
+		wantErrors   []int
+	}{
+		{"Valid", "{\"title\": \"Pond\", \"content\": \"An old silent pond...\"}\n{\"title\": \"Frog\", \"content\": \"A frog\"}\n", http.StatusOK, 2, []int{}},
+		{"Second item invalid", "{\"title\": \"Pond\", \"content\": \"An old silent pond...\"}\n{\"title\": \"Frog\"}\n", http.StatusUnprocessableEntity, 0, []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"csrf_token": {csrfToken}}
+			req := ts.multipartRequest(t, "/snippet/import", form, map[string]map[string][]byte{"file": {"old.jsonl": []byte(tt.lines)}})
+			req.Header.Set("Accept", "application/json")
+
+			code, headers, body := ts.do(t, req)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if ct := headers.Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("want a JSON report; got %q", ct)
+			}
+
+			var report importReport
+			if err := json.Unmarshal(body, &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Imported != tt.wantImported {
+				t.Errorf("want %d imported; got %d", tt.wantImported, report.Imported)
+			}
+			failed := []int{}
+			for _, item := range report.Items {
+				if len(item.Errors) > 0 {
+					failed = append(failed, item.Item)
+				} else if tt.wantImported > 0 && item.URL == "" {
+					t.Errorf("want a link to the snippet of item %d", item.Item)
+				}
+			}
+			if len(failed) != len(tt.wantErrors) || (len(failed) > 0 && failed[0] != tt.wantErrors[0]) {
+				t.Errorf("want errors on items %v; got %v", tt.wantErrors, failed)
+			}
+		})
+	}
+}
+
+func TestImportSnippetsAPI(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	lines := "{\"title\": \"Pond\", \"content\": \"An old silent pond...\"}\n{\"title\": \"Frog\", \"content\": \"A frog\"}\n"
+
+	tests := []struct {
+		name         string
+		email        string
+		wantCode     int
+		wantImported int
+	}{
+		{"Valid", "alice@example.com", http.StatusOK, 2},
+		{"No credentials", "", http.StatusUnauthorized, 0},
+		{"Invalid credentials", "bob@example.com", http.StatusUnauthorized, 0},
+		{"Suspended", "suspended@example.com", http.StatusUnauthorized, 0},
+		{"Two-factor authentication", "tom@example.com", http.StatusUnauthorized, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No session and no CSRF token, like a script.
+			form := url.Values{"visibility": {"unlisted"}}
+			req := ts.multipartRequest(t, "/api/snippets/import", form, map[string]map[string][]byte{"file": {"old.jsonl": []byte(lines)}})
+			if tt.email != "" {
+				req.SetBasicAuth(tt.email, "validPa$$word")
+			}
+
+			code, headers, body := ts.do(t, req)
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if code == http.StatusUnauthorized {
+				if headers.Get("WWW-Authenticate") == "" {
+					t.Errorf("want a WWW-Authenticate header")
+				}
+				return
+			}
+			if ct := headers.Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("want a JSON report; got %q", ct)
+			}
+
+			var report importReport
+			if err := json.Unmarshal(body, &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Imported != tt.wantImported {
+				t.Errorf("want %d imported; got %d", tt.wantImported, report.Imported)
+			}
+		})
+	}
+}
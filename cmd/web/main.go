@@ -11,7 +11,9 @@ import (
 	"time"
 
 	// my package for snippet related functionalities
+	"dsolerh/snippetbox/pkg/blobs"
 	"dsolerh/snippetbox/pkg/models"
+	"dsolerh/snippetbox/pkg/models/encrypted"
 	"dsolerh/snippetbox/pkg/models/mysql"
 
 	// mysql driver
@@ -25,6 +27,14 @@ type config struct {
 	StaticDir string
 	DSN       string
 	Secret    string
+	// comma separated id:key pairs, see encrypted.ParseKeyring
+	EncryptionKeys string
+	// where the content of the attachments is stored
+	AttachmentsDir string
+	// the most bytes the files of a snippet can hold together
+	MaxContentSize int
+	// what every user can store
+	Quota quota
 }
 
 type application struct {
@@ -32,14 +42,26 @@ type application struct {
 	errorLog      *log.Logger
 	infoLog       *log.Logger
 	session       *sessions.Session
+	sessions      models.ISessionModel
+	comments      models.ICommentModel
+	stars         models.IStarModel
+	collections   models.ICollectionModel
+	teams         models.ITeamModel
 	snippets      models.ISnippetModel
+	attachments   models.IAttachmentModel
 	users         models.IUserModel
+	blobs         blobs.Store
 	templateCache map[string]*template.Template
+	unlockLimiter *attemptLimiter
+	totpLimiter   *attemptLimiter
 }
 
 type contextKey string
 
-var contextKeyUser = contextKey("user")
+var (
+	contextKeyUser    = contextKey("user")
+	contextKeySession = contextKey("session")
+)
 
 func main() {
 	// info logger
@@ -54,6 +76,17 @@ func main() {
 	flag.StringVar(&cfg.StaticDir, "static-dir", "./ui/static", "Path to static assets")
 	flag.StringVar(&cfg.DSN, "dsn", "web:pass@tcp(localhost:3306)/snippetbox?parseTime=true", "Mysql database driver DSN (Data Source Name)")
 	flag.StringVar(&cfg.Secret, "secret", "s6Ndh+pPbnzHbS*+9Pk8qGWhTzbpa@ge", "Secret")
+	flag.StringVar(&cfg.EncryptionKeys, "encryption-keys", "", "Comma separated id:key pairs used to encrypt the snippets at rest, the first one encrypts new snippets")
+	flag.StringVar(&cfg.AttachmentsDir, "attachments-dir", "./attachments", "Path to the directory storing the attachments of the snippets")
+	flag.IntVar(&cfg.MaxContentSize, "max-content-size", 2<<20, "Maximum size in bytes of the content of a snippet, all its files together")
+	flag.IntVar(&cfg.Quota.Snippets, "quota-snippets", 1000, "Maximum number of snippets a user can have, 0 for no limit")
+	flag.Int64Var(&cfg.Quota.Bytes, "quota-bytes", 100<<20, "Maximum size in bytes of the snippets and attachments of a user, 0 for no limit")
+	flag.IntVar(&cfg.Quota.Daily, "quota-daily", 100, "Maximum number of snippets a user can create in a day, 0 for no limit")
+
+	// bootstrap commands, the server isn't started when one of them is given
+	promoteAdmin := flag.String("promote-admin", "", "Give the admin role to the user with this email and exit")
+	backfillSlugs := flag.Bool("backfill-slugs", false, "Give a slug to the snippets which don't have one yet and exit")
+	reencrypt := flag.Bool("reencrypt", false, "Encrypt every snippet with the first of the encryption keys and exit")
 
 	flag.Parse()
 
@@ -65,6 +98,52 @@ func main() {
 	// ensure close is called before exit the program
 	defer db.Close()
 
+	if *promoteAdmin != "" {
+		err = promoteToAdmin(&mysql.UserModel{DB: db}, *promoteAdmin)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Printf("%s is now an admin", *promoteAdmin)
+		return
+	}
+
+	if *backfillSlugs {
+		n, err := (&mysql.SnippetModel{DB: db}).BackfillSlugs()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Printf("Added a slug to %d snippets", n)
+		return
+	}
+
+	// snippets are only encrypted at rest when keys are given
+	var snippets models.ISnippetModel = &mysql.SnippetModel{DB: db}
+	var keys *encrypted.Keyring
+	if cfg.EncryptionKeys != "" {
+		keys, err = encrypted.ParseKeyring(cfg.EncryptionKeys)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		snippets = &encrypted.SnippetModel{ISnippetModel: snippets, Keys: keys}
+	}
+
+	if *reencrypt {
+		if keys == nil {
+			errorLog.Fatal("-reencrypt needs -encryption-keys")
+		}
+		n, err := encrypted.Reencrypt(&mysql.SnippetModel{DB: db}, keys)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Printf("Re-encrypted %d snippets with the key %s", n, keys.Primary())
+		return
+	}
+
+	// the attachments are only readable through the app
+	if err = os.MkdirAll(cfg.AttachmentsDir, 0700); err != nil {
+		errorLog.Fatal(err)
+	}
+
 	// templates
 	templateCache, err := newTemplateCache("./ui/html")
 	if err != nil {
@@ -72,7 +151,7 @@ func main() {
 	}
 
 	session := sessions.New([]byte(cfg.Secret))
-	session.Lifetime = 12 * time.Hour
+	session.Lifetime = sessionLifetime
 	session.Secure = true
 	session.SameSite = http.SameSiteStrictMode
 
@@ -83,8 +162,18 @@ func main() {
 		infoLog:  infoLog,
 
 		// db models
-		snippets: &mysql.SnippetModel{DB: db},
+		snippets: snippets,
 		users:    &mysql.UserModel{DB: db},
+		sessions: &mysql.SessionModel{DB: db},
+		comments: &mysql.CommentModel{DB: db},
+		stars:    &mysql.StarModel{DB: db},
+
+		collections: &mysql.CollectionModel{DB: db},
+		teams:       &mysql.TeamModel{DB: db},
+		attachments: &mysql.AttachmentModel{DB: db},
+
+		// the content of the attachments
+		blobs: &blobs.FS{Dir: cfg.AttachmentsDir},
 
 		// templates
 		templateCache: templateCache,
@@ -92,6 +181,10 @@ func main() {
 		// session
 		session: session,
 
+		// throttling of the passwords of protected snippets
+		unlockLimiter: newAttemptLimiter(maxUnlockAttempts, unlockWindow),
+		totpLimiter:   newAttemptLimiter(maxTOTPAttempts, totpWindow),
+
 		// config
 		cfg: cfg,
 	}
@@ -116,6 +209,12 @@ func main() {
 		WriteTimeout: 5 * time.Second,
 	}
 
+	// remove expired sessions and snippets, and orphaned attachments, in the
+	// background
+	go app.cleanupSessions(sessionCleanupInterval)
+	go app.cleanupSnippets(snippetCleanupInterval)
+	go app.cleanupAttachments(attachmentCleanupInterval)
+
 	app.infoLog.Printf("Starting server on %s", app.cfg.Addr)
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
 	app.errorLog.Fatal(err)
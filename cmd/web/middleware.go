@@ -5,6 +5,7 @@ import (
 	"dsolerh/snippetbox/pkg/models"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/justinas/nosurf"
 )
@@ -28,6 +29,32 @@ func noSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
+// writeDeadline gives the handler d to write its response, instead of the
+// WriteTimeout of the server, for the responses which take longer. It must
+// come before app.session.Enable, whose ResponseWriter can't set deadlines.
+func (app *application) writeDeadline(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d))
+			if err != nil {
+				app.errorLog.Print(err)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitBody stops reading the body of the requests after n bytes. It must
+// come before anything parsing the form, like noSurf.
+func limitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL)
@@ -39,6 +66,11 @@ func (app *application) panicRecover(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				// The handler gave up on a response it had started, let the
+				// server close the connection.
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
 				w.Header().Set("Connection", "close")
 				app.serverError(w, fmt.Errorf("%s", err))
 			}
@@ -57,17 +89,95 @@ func (app *application) requireAuthenticatedUser(next http.Handler) http.Handler
 	})
 }
 
+// requireRole only lets through authenticated users with one of the given
+// roles. It's meant to be appended to a middleware chain which already runs
+// app.authenticate.
+func (app *application) requireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := app.authenticatedUser(r)
+			if user == nil {
+				http.Redirect(w, r, "/user/login", http.StatusFound)
+				return
+			}
+			for _, role := range roles {
+				if user.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			app.clientError(w, http.StatusForbidden)
+		})
+	}
+}
+
+// basicAuth authenticates the requests of scripts, which have no session and
+// no CSRF token, with the email and the password of the user sent as HTTP
+// basic auth. The password alone isn't enough to log in to accounts with
+// two-factor authentication enabled, so they can't use it.
+func (app *application) basicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email, password, ok := r.BasicAuth()
+		if !ok {
+			app.unauthorized(w)
+			return
+		}
+
+		id, err := app.users.Authenticate(email, password)
+		if err == models.ErrInvalidCredentials || err == models.ErrSuspended {
+			app.unauthorized(w)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		user, err := app.users.Get(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if user.TOTPEnabled {
+			app.unauthorized(w)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		exist := app.session.Exists(r, "userID")
-		if !exist {
+		token := app.session.GetString(r, "sessionToken")
+		if token == "" {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		user, err := app.users.Get(app.session.GetInt(r, "userID"))
+		// The session may have expired or been revoked from another device.
+		sess, err := app.sessions.Get(token)
 		if err == models.ErrNoRecord {
-			app.session.Remove(r, "userID")
+			app.session.Remove(r, "sessionToken")
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if time.Since(sess.LastSeen) > sessionTouchInterval {
+			err = app.sessions.Touch(sess.ID)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+
+		user, err := app.users.Get(sess.UserID)
+		if err == models.ErrNoRecord || (err == nil && user.Suspended) {
+			app.endSession(r)
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -77,6 +187,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		ctx = context.WithValue(ctx, contextKeySession, sess)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
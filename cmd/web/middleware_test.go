@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"dsolerh/snippetbox/pkg/models"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -55,3 +57,37 @@ func TestSecureHeaders(t *testing.T) {
 		t.Errorf("want body to equal %q", "OK")
 	}
 }
+
+func TestRequireRole(t *testing.T) {
+	app := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Ok"))
+	})
+
+	tests := []struct {
+		desc     string
+		user     *models.User
+		wantCode int
+	}{
+		{"Anonymous", nil, http.StatusFound},
+		{"User", &models.User{ID: 1, Role: models.RoleUser}, http.StatusForbidden},
+		{"Moderator", &models.User{ID: 1, Role: models.RoleModerator}, http.StatusOK},
+		{"Admin", &models.User{ID: 1, Role: models.RoleAdmin}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.user != nil {
+				r = r.WithContext(context.WithValue(r.Context(), contextKeyUser, tt.user))
+			}
+
+			app.requireRole(models.RoleModerator, models.RoleAdmin)(next).ServeHTTP(rr, r)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rr.Code)
+			}
+		})
+	}
+}
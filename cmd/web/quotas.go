@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"mime/multipart"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// quota is what a user can store, a zero limit meaning there is none. Expired
+// snippets don't count, see models.Usage.
+type quota struct {
+	// the most snippets a user can have
+	Snippets int
+	// the most bytes the files and the attachments of their snippets can
+	// take together
+	Bytes int64
+	// the most snippets a user can create in 24 hours
+	Daily int
+}
+
+// snippetSize returns how many bytes a snippet with the files and the
+// attachments counts for in the quotas.
+func snippetSize(files []*models.File, attachments []*multipart.FileHeader) int64 {
+	var n int64
+	for _, f := range files {
+		n += int64(len(f.Content))
+	}
+	for _, fh := range attachments {
+		n += fh.Size
+	}
+	return n
+}
+
+// checkQuota adds an error to the form when creating n snippets of size bytes
+// together would take the user over one of their quotas. Edits create no
+// snippets and size is then how many bytes they add, only what's added
+// being checked so users over a lowered quota can still shrink their
+// snippets.
+func (app *application) checkQuota(form *forms.Form, userID, n int, size int64) error {
+	u, err := app.snippets.Usage(userID)
+	if err != nil {
+		return err
+	}
+
+	q := app.cfg.Quota
+	switch {
+	case q.Daily > 0 && n > 0 && u.CreatedToday+n > q.Daily:
+		form.Errors.Add("quota", fmt.Sprintf("You can't create more than %d snippets a day, try again later", q.Daily))
+	case q.Snippets > 0 && n > 0 && u.Snippets+n > q.Snippets:
+		form.Errors.Add("quota", fmt.Sprintf("You can't have more than %d snippets, delete some of them first", q.Snippets))
+	case q.Bytes > 0 && size > 0 && u.Bytes+size > q.Bytes:
+		form.Errors.Add("quota", fmt.Sprintf("This snippet doesn't fit in your %s of storage, you already use %s", fileSize(q.Bytes), fileSize(u.Bytes)))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCreateSnippetQuota(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	// The mocked usage of alice is 5 snippets, 2 KB and 2 snippets today.
+	tests := []struct {
+		name      string
+		quota     quota
+		content   string
+		wantCode  int
+		wantError string
+	}{
+		{"Under the quotas", quota{Snippets: 6, Bytes: 4096, Daily: 3}, "An old silent pond...", http.StatusSeeOther, ""},
+		{"No limits", quota{}, "An old silent pond...", http.StatusSeeOther, ""},
+		{"Too many snippets", quota{Snippets: 5}, "An old silent pond...", http.StatusOK, "You can&#39;t have more than 5 snippets"},
+		{"Too many today", quota{Daily: 2}, "An old silent pond...", http.StatusOK, "You can&#39;t create more than 2 snippets a day"},
+		{"Too big", quota{Bytes: 4096}, strings.Repeat("a", 2049), http.StatusOK, "This snippet doesn&#39;t fit in your 4.0 KB of storage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.cfg.Quota = tt.quota
+
+			form := url.Values{}
+			form.Add("title", "O snail")
+			form.Add("file_content", tt.content)
+			form.Add("expires", "1w")
+			form.Add("visibility", "public")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantError != "" && !bytes.Contains(body, []byte(tt.wantError)) {
+				t.Errorf("want body to contain %q", tt.wantError)
+			}
+		})
+	}
+}
+
+func TestEditSnippetQuota(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/s/pond/edit")
+	csrfToken := extractCSRFToken(t, body)
+
+	// The mocked usage of alice is 5 snippets, 2 KB and 2 snippets today,
+	// the files of the pond snippet take 71 bytes.
+	tests := []struct {
+		name      string
+		quota     quota
+		content   string
+		wantCode  int
+		wantError string
+	}{
+		{"Under the quota", quota{Bytes: 4096}, strings.Repeat("a", 2048), http.StatusSeeOther, ""},
+		{"Too big", quota{Bytes: 4096}, strings.Repeat("a", 2122), http.StatusOK, "This snippet doesn&#39;t fit in your 4.0 KB of storage"},
+		{"Smaller over the quota", quota{Bytes: 1024}, "An old pond", http.StatusSeeOther, ""},
+		{"No snippets left today", quota{Snippets: 5, Daily: 2}, "An old pond", http.StatusSeeOther, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.cfg.Quota = tt.quota
+
+			form := url.Values{}
+			form.Add("title", "An old pond")
+			form.Add("file_content", tt.content)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/s/pond/edit", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantError != "" && !bytes.Contains(body, []byte(tt.wantError)) {
+				t.Errorf("want body to contain %q", tt.wantError)
+			}
+		})
+	}
+}
+
+func TestUserSettingsUsage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	code, _, body := ts.get(t, "/user/settings")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{"<td>5 of 100</td>", "<td>2.0 KB of 1.0 MB</td>", "<td>2 of 10</td>"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// wrong passwords allowed for a protected snippet within unlockWindow
+	maxUnlockAttempts = 5
+	unlockWindow      = 15 * time.Minute
+)
+
+// attemptLimiter counts failed attempts per key, e.g. wrong passwords for a
+// given snippet, and blocks further attempts once there have been too many
+// of them within a time window.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	attempts map[int]*attemptWindow
+}
+
+type attemptWindow struct {
+	count int
+	start time.Time
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		attempts: map[int]*attemptWindow{},
+	}
+}
+
+// Allowed reports whether a new attempt can be made for the key.
+func (l *attemptLimiter) Allowed(key int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.attempts[key]
+	if !ok || time.Since(a.start) > l.window {
+		return true
+	}
+	return a.count < l.max
+}
+
+// Fail records a failed attempt for the key.
+func (l *attemptLimiter) Fail(key int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.attempts[key]
+	if !ok || time.Since(a.start) > l.window {
+		l.prune()
+		l.attempts[key] = &attemptWindow{count: 1, start: time.Now()}
+		return
+	}
+	a.count++
+}
+
+// Reset forgets the failed attempts for the key, e.g. once the right password
+// has been given.
+func (l *attemptLimiter) Reset(key int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
+
+// prune forgets the windows which are over so the map doesn't grow forever.
+// It must be called with the lock held.
+func (l *attemptLimiter) prune() {
+	for key, a := range l.attempts {
+		if time.Since(a.start) > l.window {
+			delete(l.attempts, key)
+		}
+	}
+}
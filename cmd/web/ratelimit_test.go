@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	l := newAttemptLimiter(3, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allowed(1) {
+			t.Fatalf("want attempt %d to be allowed", i+1)
+		}
+		l.Fail(1)
+	}
+	if l.Allowed(1) {
+		t.Errorf("want attempts to be blocked after 3 failures")
+	}
+	if !l.Allowed(2) {
+		t.Errorf("want the other keys to be unaffected")
+	}
+
+	// Once the window is over the key is allowed again.
+	l.attempts[1].start = time.Now().Add(-2 * time.Hour)
+	if !l.Allowed(1) {
+		t.Errorf("want attempts to be allowed once the window is over")
+	}
+	l.Fail(1)
+	if l.attempts[1].count != 1 {
+		t.Errorf("want a new window to start; got %d failures", l.attempts[1].count)
+	}
+}
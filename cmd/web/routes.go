@@ -3,6 +3,8 @@ package main
 import (
 	"net/http"
 
+	"dsolerh/snippetbox/pkg/models"
+
 	"github.com/bmizerany/pat"
 	"github.com/justinas/alice"
 )
@@ -11,14 +13,54 @@ func (app *application) routes() http.Handler {
 	// create a middleware chain
 	standardMiddleware := alice.New(app.panicRecover, app.logRequest, secureHeaders)
 	dynamicMiddleware := alice.New(app.session.Enable, noSurf, app.authenticate)
+	adminMiddleware := dynamicMiddleware.Append(app.requireRole(models.RoleAdmin))
+
+	apiMiddleware := alice.New(app.basicAuth)
 
 	mux := pat.New()
 
 	// routes
 	mux.Get("/", dynamicMiddleware.ThenFunc(app.home))
+	mux.Get("/feed.atom", http.HandlerFunc(app.latestFeed))
+	mux.Get("/feed.rss", http.HandlerFunc(app.latestFeed))
+	mux.Get("/users/:id/feed.atom", http.HandlerFunc(app.userFeed))
+	mux.Get("/users/:id/feed.rss", http.HandlerFunc(app.userFeed))
 	mux.Get("/snippet/create", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createSnippetForm))
-	mux.Post("/snippet/create", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createSnippet))
-	mux.Get("/snippet/:id", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Post("/snippet/create", alice.New(limitBody(app.maxFilesBodySize())).Extend(dynamicMiddleware).Append(app.requireAuthenticatedUser).ThenFunc(app.createSnippet))
+	mux.Get("/snippet/import", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.importSnippetsForm))
+	mux.Post("/snippet/import", alice.New(limitBody(maxImportSize+1<<20)).Extend(dynamicMiddleware).Append(app.requireAuthenticatedUser).ThenFunc(app.importSnippets))
+	mux.Get("/snippet/:id", dynamicMiddleware.ThenFunc(app.showSnippetByID))
+	mux.Get("/s/:slug", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Post("/s/:slug/burn", dynamicMiddleware.ThenFunc(app.burnSnippet))
+	mux.Post("/s/:slug/unlock", dynamicMiddleware.ThenFunc(app.unlockSnippet))
+	mux.Get("/s/:slug/zip", dynamicMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Get("/s/:slug/attachments/:id", dynamicMiddleware.ThenFunc(app.downloadAttachment))
+	mux.Get("/s/:slug/edit", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.editSnippetForm))
+	mux.Post("/s/:slug/edit", alice.New(limitBody(app.maxFilesBodySize())).Extend(dynamicMiddleware).Append(app.requireAuthenticatedUser).ThenFunc(app.editSnippet))
+	mux.Get("/s/:slug/fork", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.forkSnippetForm))
+	mux.Post("/s/:slug/star", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.starSnippet))
+	mux.Post("/s/:slug/collect", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.addToCollection))
+	mux.Post("/s/:slug/comments", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createComment))
+	mux.Get("/s/:slug/comments/:id/edit", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.editCommentForm))
+	mux.Post("/s/:slug/comments/:id/edit", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.editComment))
+	mux.Post("/s/:slug/comments/:id/delete", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.deleteComment))
+
+	// collection routes
+	mux.Get("/c/:slug", dynamicMiddleware.ThenFunc(app.showCollection))
+	mux.Post("/c/:slug/rename", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.renameCollection))
+	mux.Post("/c/:slug/share", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.shareCollection))
+	mux.Post("/c/:slug/delete", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.deleteCollection))
+	mux.Post("/c/:slug/remove", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.removeFromCollection))
+	mux.Post("/c/:slug/move", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.moveInCollection))
+
+	// team routes
+	mux.Get("/t/:slug", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.showTeam))
+	mux.Post("/t/:slug/invite", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.inviteToTeam))
+	mux.Post("/t/:slug/members/:id/role", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.setMemberRole))
+	mux.Post("/t/:slug/members/:id/remove", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.removeMember))
+
+	// api routes, for scripts
+	mux.Post("/api/snippets/import", alice.New(limitBody(maxImportSize+1<<20)).Extend(apiMiddleware).ThenFunc(app.importSnippets))
 
 	mux.Get("/file", http.HandlerFunc(app.downloadHandler))
 	mux.Get("/ping", http.HandlerFunc(ping))
@@ -28,7 +70,37 @@ func (app *application) routes() http.Handler {
 	mux.Post("/user/signup", dynamicMiddleware.ThenFunc(app.signupUser))
 	mux.Get("/user/login", dynamicMiddleware.ThenFunc(app.loginUserForm))
 	mux.Post("/user/login", dynamicMiddleware.ThenFunc(app.loginUser))
+	mux.Get("/user/login/totp", dynamicMiddleware.ThenFunc(app.loginTOTPForm))
+	mux.Post("/user/login/totp", dynamicMiddleware.ThenFunc(app.loginTOTP))
 	mux.Post("/user/logout", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.logoutUser))
+	mux.Get("/user/snippets", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.userSnippets))
+	mux.Get("/user/starred", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.starredSnippets))
+	mux.Get("/user/collections", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.userCollections))
+	mux.Post("/user/collections", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createCollection))
+	mux.Get("/user/teams", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.userTeams))
+	mux.Post("/user/teams", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createTeam))
+	mux.Get("/user/invitations/:token", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.showInvitation))
+	mux.Post("/user/invitations/:token/accept", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.acceptInvitation))
+	mux.Post("/user/invitations/:token/decline", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.declineInvitation))
+	mux.Get("/user/settings", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.userSettings))
+	mux.Get("/user/sessions", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.listSessions))
+	mux.Post("/user/sessions/revoke-others", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.revokeOtherSessions))
+	mux.Post("/user/sessions/:id/revoke", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.revokeSession))
+	mux.Post("/user/totp/setup", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.setupTOTP))
+	mux.Post("/user/totp/enable", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.enableTOTP))
+	mux.Post("/user/totp/disable", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.disableTOTP))
+	mux.Get("/user/export", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.exportUserData))
+	mux.Get("/user/export/snippets", alice.New(app.writeDeadline(exportTimeout)).Extend(dynamicMiddleware).Append(app.requireAuthenticatedUser).ThenFunc(app.exportSnippets))
+	mux.Get("/user/delete", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.deleteUserForm))
+	mux.Post("/user/delete", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.deleteUser))
+
+	// admin routes
+	mux.Get("/admin", adminMiddleware.ThenFunc(app.adminDashboard))
+	mux.Get("/admin/users", adminMiddleware.ThenFunc(app.adminUsers))
+	mux.Post("/admin/users/:id/suspend", adminMiddleware.ThenFunc(app.adminSuspendUser))
+	mux.Get("/admin/snippets", adminMiddleware.ThenFunc(app.adminSnippets))
+	mux.Post("/admin/snippets/:id/delete", adminMiddleware.ThenFunc(app.adminDeleteSnippet))
+	mux.Post("/admin/snippets/:id/expire", adminMiddleware.ThenFunc(app.adminExpireSnippet))
 
 	// static files serve
 	fileServer := http.FileServer(http.Dir(app.cfg.StaticDir))
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+const (
+	// how long a login lasts, both for the cookie and the server-side session
+	sessionLifetime = 12 * time.Hour
+	// the last seen time of a session is only updated once per interval, so
+	// we don't write to the database on every request
+	sessionTouchInterval = time.Minute
+	// how often expired sessions are removed from the database
+	sessionCleanupInterval = 30 * time.Minute
+)
+
+// startSession logs the user in by creating a server-side session and storing
+// its token in the session cookie.
+func (app *application) startSession(r *http.Request, userID int) error {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	token, err := app.sessions.Create(userID, ip, r.UserAgent(), sessionLifetime)
+	if err != nil {
+		return err
+	}
+
+	app.session.Put(r, "sessionToken", token)
+	return nil
+}
+
+// endSession logs the user out by deleting the server-side session, so the
+// cookie is useless even if somebody kept a copy of it.
+func (app *application) endSession(r *http.Request) error {
+	token := app.session.GetString(r, "sessionToken")
+	app.session.Remove(r, "sessionToken")
+	if token == "" {
+		return nil
+	}
+	return app.sessions.Delete(token)
+}
+
+// currentSession returns the server-side session of the request, if any.
+func (app *application) currentSession(r *http.Request) *models.Session {
+	s, ok := r.Context().Value(contextKeySession).(*models.Session)
+	if !ok {
+		return nil
+	}
+	return s
+}
+
+func (app *application) listSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := app.sessions.ForUser(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "sessions.page.tmpl", &templateData{
+		Sessions:       sessions,
+		CurrentSession: app.currentSession(r),
+	})
+}
+
+func (app *application) revokeSession(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Revoking the current session is just a logout, which has its own route.
+	if current := app.currentSession(r); current != nil && current.ID == id {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.sessions.Revoke(app.authenticatedUser(r).ID, id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "The session has been revoked.")
+	http.Redirect(w, r, "/user/sessions", http.StatusSeeOther)
+}
+
+func (app *application) revokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	current := app.currentSession(r)
+	if current == nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := app.sessions.RevokeOthers(current.UserID, current.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "All your other sessions have been revoked.")
+	http.Redirect(w, r, "/user/sessions", http.StatusSeeOther)
+}
+
+// cleanupSessions periodically removes expired sessions from the database. It
+// is meant to be run in its own goroutine for the lifetime of the server.
+func (app *application) cleanupSessions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := app.sessions.DeleteExpired()
+		if err != nil {
+			app.errorLog.Print(err)
+			continue
+		}
+		if n > 0 {
+			app.infoLog.Printf("Removed %d expired sessions", n)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// starSnippet stars or unstars a snippet. The form says which one is wanted
+// rather than toggling, so submitting it twice doesn't undo it.
+func (app *application) starSnippet(w http.ResponseWriter, r *http.Request) {
+	s, err := app.readableSnippet(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	switch r.PostForm.Get("star") {
+	case "true":
+		err = app.stars.Star(user.ID, s.ID)
+	case "false":
+		err = app.stars.Unstar(user.ID, s.ID)
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+func (app *application) starredSnippets(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.StarredBy(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "starred.page.tmpl", &templateData{Snippets: s})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestStarSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+
+	_, _, body := ts.get(t, "/s/fork")
+	if !bytes.Contains(body, []byte("2 stars")) || !bytes.Contains(body, []byte("<button>Unstar</button>")) {
+		t.Errorf("want the star count and the unstar button")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		star     string
+		wantCode int
+	}{
+		{"Star", "/s/pond/star", "true", http.StatusSeeOther},
+		{"Unstar", "/s/fork/star", "false", http.StatusSeeOther},
+		{"Invalid", "/s/pond/star", "toggle", http.StatusBadRequest},
+		{"Burn after reading", "/s/burn/star", "true", http.StatusNotFound},
+		{"Non-existent snippet", "/s/nope/star", "true", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("star", tt.star)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestStarredSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/user/starred")
+	if code != http.StatusFound || headers.Get("Location") != "/user/login" {
+		t.Errorf("want anonymous users to be sent to the login page; got %d", code)
+	}
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/user/starred")
+	if !bytes.Contains(body, []byte("A new silent pond")) {
+		t.Errorf("want the starred snippets to be listed")
+	}
+}
+
+func TestHomeSort(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantFirst string
+	}{
+		{"Newest", "/", "An old silent pond"},
+		{"Popular", "/?sort=popular", "A new silent pond"},
+		{"Unknown", "/?sort=random", "An old silent pond"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, tt.urlPath)
+			first := bytes.Index(body, []byte("An old silent pond"))
+			second := bytes.Index(body, []byte("A new silent pond"))
+			if first < 0 || second < 0 {
+				t.Fatalf("want both snippets to be listed")
+			}
+			if got := first < second; got != (tt.wantFirst == "An old silent pond") {
+				t.Errorf("want %q to be listed first", tt.wantFirst)
+			}
+		})
+	}
+}
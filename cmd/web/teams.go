@@ -0,0 +1,329 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// teamURL returns the link to the page of a team, which is built from its
+// slug.
+func teamURL(t *models.Team) string {
+	return "/t/" + t.Slug
+}
+
+// memberTeam returns the team from the URL and the role of the viewer in it.
+// Only the members of a team can see it, anybody else gets the ErrNoRecord
+// error as if it didn't exist.
+func (app *application) memberTeam(r *http.Request) (*models.Team, string, error) {
+	t, err := app.teams.GetBySlug(r.URL.Query().Get(":slug"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	role, err := app.teams.Role(t.ID, app.viewerID(r))
+	if err != nil {
+		return nil, "", err
+	}
+	return t, role, nil
+}
+
+// ownedTeam returns the team from the URL if the viewer is one of its owners.
+// Simple members get the ErrNoRecord error, the same as everybody else.
+func (app *application) ownedTeam(r *http.Request) (*models.Team, error) {
+	t, role, err := app.memberTeam(r)
+	if err != nil {
+		return nil, err
+	}
+	if role != models.TeamRoleOwner {
+		return nil, models.ErrNoRecord
+	}
+	return t, nil
+}
+
+// snippetTeam returns the id of the team chosen in the team field of the form
+// creating a snippet, or 0 for a snippet of the viewer's own. Snippets can
+// only be created for the teams the viewer is a member of.
+func (app *application) snippetTeam(r *http.Request, form *forms.Form) (int, error) {
+	if form.Get("team") == "" {
+		return 0, nil
+	}
+
+	teamID, err := strconv.Atoi(form.Get("team"))
+	if err != nil || teamID < 1 {
+		form.Errors.Add("team", "This field is invalid")
+		return 0, nil
+	}
+
+	_, err = app.teams.Role(teamID, app.authenticatedUser(r).ID)
+	if err == models.ErrNoRecord {
+		form.Errors.Add("team", "You aren't a member of this team")
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return teamID, nil
+}
+
+func (app *application) userTeams(w http.ResponseWriter, r *http.Request) {
+	app.renderTeams(w, r, forms.New(nil))
+}
+
+// renderTeams shows the teams of the viewer.
+func (app *application) renderTeams(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	teams, err := app.teams.ForUser(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "teams.page.tmpl", &templateData{
+		Teams: teams,
+		Form:  form,
+	})
+}
+
+func (app *application) createTeam(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name")
+	form.MaxLength("name", 100)
+	if !form.Valid() {
+		app.renderTeams(w, r, form)
+		return
+	}
+
+	t := &models.Team{Name: form.Get("name")}
+	_, err := app.teams.Insert(t, app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your team has been created, invite its members from here.")
+	http.Redirect(w, r, teamURL(t), http.StatusSeeOther)
+}
+
+// showInvitation lets whoever followed the link of an invitation join the
+// team or decline. The token in the link is what was sent to the email, the
+// email of the account doesn't need to match it.
+func (app *application) showInvitation(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get(":token")
+	i, err := app.teams.Invitation(token)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "invitation.page.tmpl", &templateData{
+		Invitation:      i,
+		InvitationToken: token,
+	})
+}
+
+func (app *application) acceptInvitation(w http.ResponseWriter, r *http.Request) {
+	err := app.teams.Accept(r.URL.Query().Get(":token"), app.authenticatedUser(r).ID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "You have joined the team.")
+	http.Redirect(w, r, "/user/teams", http.StatusSeeOther)
+}
+
+func (app *application) declineInvitation(w http.ResponseWriter, r *http.Request) {
+	err := app.teams.Decline(r.URL.Query().Get(":token"))
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/user/teams", http.StatusSeeOther)
+}
+
+func (app *application) showTeam(w http.ResponseWriter, r *http.Request) {
+	t, role, err := app.memberTeam(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.renderTeam(w, r, t, role, forms.New(nil))
+}
+
+// renderTeam shows the members and the snippets of a team to one of its
+// members. The form is the one inviting new members, only shown to owners.
+func (app *application) renderTeam(w http.ResponseWriter, r *http.Request, t *models.Team, role string, form *forms.Form) {
+	members, err := app.teams.Members(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	snippets, err := app.snippets.ForTeam(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "team.page.tmpl", &templateData{
+		Team:     t,
+		TeamRole: role,
+		Members:  members,
+		Snippets: snippets,
+		Form:     form,
+	})
+}
+
+func (app *application) inviteToTeam(w http.ResponseWriter, r *http.Request) {
+	t, err := app.ownedTeam(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("email")
+	form.MaxLength("email", 255)
+	form.MatchesPattern("email", forms.EmailRX)
+	var token string
+	if form.Valid() {
+		token, err = app.teams.Invite(t.ID, form.Get("email"), app.authenticatedUser(r).ID)
+		if err == models.ErrAlreadyMember {
+			form.Errors.Add("email", "This person is already a member of the team")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	if !form.Valid() {
+		app.renderTeam(w, r, t, models.TeamRoleOwner, form)
+		return
+	}
+
+	// We don't send emails, so the owner sends the link themselves. Whoever
+	// has it can join, which is why it isn't shown anywhere else.
+	app.session.Put(r, "flash", "Send this link to "+form.Get("email")+" to invite them, it can only be used once: "+siteURL(r)+"/user/invitations/"+token)
+	http.Redirect(w, r, teamURL(t), http.StatusSeeOther)
+}
+
+// teamMember returns the id of the member from the URL, or 0 if it isn't
+// valid.
+func teamMember(r *http.Request) int {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		return 0
+	}
+	return id
+}
+
+// setMemberRole makes a member of the team an owner, or a simple member
+// again.
+func (app *application) setMemberRole(w http.ResponseWriter, r *http.Request) {
+	t, err := app.ownedTeam(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	role := r.PostForm.Get("role")
+	if role != models.TeamRoleOwner && role != models.TeamRoleMember {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := teamMember(r)
+	_, err = app.teams.Role(t.ID, userID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.teams.SetRole(t.ID, userID, role)
+	if err == models.ErrLastOwner {
+		app.session.Put(r, "flash", "The team needs at least one owner.")
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, teamURL(t), http.StatusSeeOther)
+}
+
+// removeMember takes a member out of the team. Owners can remove anybody and
+// every member can remove themselves to leave the team.
+func (app *application) removeMember(w http.ResponseWriter, r *http.Request) {
+	t, role, err := app.memberTeam(r)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	userID := teamMember(r)
+	leaving := userID == app.authenticatedUser(r).ID
+	if !leaving && role != models.TeamRoleOwner {
+		app.notFound(w)
+		return
+	}
+
+	err = app.teams.RemoveMember(t.ID, userID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err == models.ErrLastOwner {
+		app.session.Put(r, "flash", "The team needs at least one owner, make someone else an owner first.")
+		http.Redirect(w, r, teamURL(t), http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if leaving {
+		app.session.Put(r, "flash", "You have left the team.")
+		http.Redirect(w, r, "/user/teams", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, teamURL(t), http.StatusSeeOther)
+}
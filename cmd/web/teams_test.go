@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"dsolerh/snippetbox/pkg/models/mock"
+)
+
+func TestShowTeam(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name      string
+		email     string
+		urlPath   string
+		wantCode  int
+		wantOwner bool
+	}{
+		{"Owner", "alice@example.com", "/t/devs", http.StatusOK, true},
+		{"Not a member", "ada@example.com", "/t/devs", http.StatusNotFound, false},
+		{"Anonymous", "", "/t/devs", http.StatusFound, false},
+		{"Non-existent", "alice@example.com", "/t/nope", http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if !bytes.Contains(body, []byte("A team haiku")) || !bytes.Contains(body, []byte("Tom")) {
+				t.Errorf("want the snippets and the members of the team to be listed")
+			}
+			if got := bytes.Contains(body, []byte("Send invitation")); got != tt.wantOwner {
+				t.Errorf("want the invitation form %v; got %v", tt.wantOwner, got)
+			}
+		})
+	}
+}
+
+func TestCreateTeam(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/user/teams")
+	if !bytes.Contains(body, []byte("<a href='/t/devs'>Developers</a>")) {
+		t.Errorf("want the user's teams to be listed")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		team         string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Valid", "Designers", http.StatusSeeOther, "/t/new"},
+		{"Empty", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.team)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/user/teams", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := headers.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+}
+
+func TestManageTeam(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		fields   url.Values
+		wantCode int
+		wantBody []byte
+	}{
+		{"Invite", "alice@example.com", "/t/devs/invite", url.Values{"email": {"bob@example.com"}}, http.StatusSeeOther, nil},
+		{"Invite a member", "alice@example.com", "/t/devs/invite", url.Values{"email": {"tom@example.com"}}, http.StatusOK, []byte("already a member")},
+		{"Invite an invalid email", "alice@example.com", "/t/devs/invite", url.Values{"email": {"bob"}}, http.StatusOK, []byte("This field is invalid")},
+		{"Invite as a non-member", "ada@example.com", "/t/devs/invite", url.Values{"email": {"bob@example.com"}}, http.StatusNotFound, nil},
+		{"Make owner", "alice@example.com", "/t/devs/members/2/role", url.Values{"role": {"owner"}}, http.StatusSeeOther, nil},
+		{"Demote the last owner", "alice@example.com", "/t/devs/members/1/role", url.Values{"role": {"member"}}, http.StatusSeeOther, nil},
+		{"Invalid role", "alice@example.com", "/t/devs/members/2/role", url.Values{"role": {"admin"}}, http.StatusBadRequest, nil},
+		{"Role of a non-member", "alice@example.com", "/t/devs/members/3/role", url.Values{"role": {"owner"}}, http.StatusNotFound, nil},
+		{"Role as a non-member", "ada@example.com", "/t/devs/members/2/role", url.Values{"role": {"owner"}}, http.StatusNotFound, nil},
+		{"Remove a member", "alice@example.com", "/t/devs/members/2/remove", nil, http.StatusSeeOther, nil},
+		{"Remove as a non-member", "ada@example.com", "/t/devs/members/2/remove", nil, http.StatusNotFound, nil},
+		{"Accept invitation", "ada@example.com", "/user/invitations/" + mock.MockInvitationToken + "/accept", nil, http.StatusSeeOther, nil},
+		{"Accept with an unknown token", "ada@example.com", "/user/invitations/1/accept", nil, http.StatusNotFound, nil},
+		{"Decline invitation", "ada@example.com", "/user/invitations/" + mock.MockInvitationToken + "/decline", nil, http.StatusSeeOther, nil},
+		{"Decline with an unknown token", "ada@example.com", "/user/invitations/1/decline", nil, http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email)
+			_, _, body := ts.get(t, "/user/teams")
+
+			form := url.Values{}
+			for k, v := range tt.fields {
+				form[k] = v
+			}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLeaveTeam(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/t/devs")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ := ts.postForm(t, "/t/devs/members/1/remove", form)
+	if code != http.StatusSeeOther || headers.Get("Location") != "/t/devs" {
+		t.Errorf("want the last owner to be sent back to the team; got %d to %q", code, headers.Get("Location"))
+	}
+
+	_, _, body = ts.get(t, "/t/devs")
+	if !bytes.Contains(body, []byte("The team needs at least one owner")) {
+		t.Errorf("want a flash explaining why the owner can't leave")
+	}
+}
+
+func TestTeamInvitations(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/t/devs")
+
+	form := url.Values{}
+	form.Add("email", "ada@example.com")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/t/devs/invite", form)
+
+	link := "/user/invitations/" + mock.MockInvitationToken
+	_, _, body = ts.get(t, "/t/devs")
+	if !bytes.Contains(body, []byte(link)) {
+		t.Errorf("want the flash to give the owner the link of the invitation")
+	}
+
+	ts = newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "ada@example.com")
+	_, _, body = ts.get(t, "/user/teams")
+	if bytes.Contains(body, []byte("Developers")) {
+		t.Errorf("want the invitation not to be claimable by the email alone")
+	}
+
+	code, _, body := ts.get(t, link)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("Developers")) || !bytes.Contains(body, []byte(link+"/accept")) {
+		t.Errorf("want the invitation to be shown from its link; got %d", code)
+	}
+
+	code, _, _ = ts.get(t, "/user/invitations/1")
+	if code != http.StatusNotFound {
+		t.Errorf("want %d for an unknown token; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestEditSnippet(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		title    string
+		wantCode int
+	}{
+		{"Own snippet", "alice@example.com", "/s/pond/edit", "An old pond", http.StatusSeeOther},
+		{"Team snippet", "alice@example.com", "/s/team/edit", "A team haiku", http.StatusSeeOther},
+		{"Empty title", "alice@example.com", "/s/pond/edit", "", http.StatusOK},
+		{"Someone else's snippet", "ada@example.com", "/s/pond/edit", "Mine now", http.StatusNotFound},
+		{"Someone else's team snippet", "ada@example.com", "/s/team/edit", "Mine now", http.StatusNotFound},
+		{"Encrypted snippet", "alice@example.com", "/s/encrypted/edit", "Plaintext", http.StatusNotFound},
+		{"Burn after reading", "alice@example.com", "/s/burn/edit", "Read", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email)
+			_, _, body := ts.get(t, "/user/teams")
+
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("file_content", "Frog jumps in")
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestEditProtectedTeamSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Alice is a member of the team but didn't create the snippet, she must
+	// give its password before editing it like before reading it.
+	ts.login(t, "alice@example.com")
+	if code, _, _ := ts.get(t, "/s/team-protected/edit"); code != http.StatusNotFound {
+		t.Errorf("want %d until the snippet is unlocked; got %d", http.StatusNotFound, code)
+	}
+
+	_, _, body := ts.get(t, "/s/team-protected")
+	form := url.Values{}
+	form.Add("password", mock.MockSnippetPassword)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	if code, _, _ := ts.postForm(t, "/s/team-protected/unlock", form); code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	code, _, body := ts.get(t, "/s/team-protected/edit")
+	if code != http.StatusOK {
+		t.Errorf("want %d once unlocked; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("Kept among ourselves")) {
+		t.Errorf("want the content in the edit form once unlocked")
+	}
+}
+
+func TestTeamSnippet(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+	}{
+		{"Member", "alice@example.com", http.StatusOK},
+		{"Not a member", "ada@example.com", http.StatusNotFound},
+		{"Anonymous", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, "/s/team")
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if code == http.StatusOK && !bytes.Contains(body, []byte("<a href='/s/team/edit'>Edit</a>")) {
+				t.Errorf("want members of the team to be able to edit its snippets")
+			}
+		})
+	}
+}
+
+func TestCreateTeamSnippet(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		team     string
+		wantCode int
+	}{
+		{"Member", "alice@example.com", "1", http.StatusSeeOther},
+		{"Not a member", "ada@example.com", "1", http.StatusOK},
+		{"Invalid team", "alice@example.com", "devs", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t, tt.email)
+			_, _, body := ts.get(t, "/snippet/create")
+
+			form := url.Values{}
+			form.Add("title", "A team haiku")
+			form.Add("file_content", "Written together")
+			form.Add("expires", "1d")
+			form.Add("visibility", "private")
+			form.Add("team", tt.team)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
+	"net/url"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"dsolerh/snippetbox/pkg/forms"
@@ -16,7 +19,36 @@ type templateData struct {
 	AuthenticatedUser *models.User
 	Form              *forms.Form
 	Snippet           *models.Snippet
+	Attachments       []*models.Attachment
+	Burned            bool
+	Starred           bool
+	CanEdit           bool
+	Sort              string
+	Parent            *models.Snippet
+	Forks             []*models.Snippet
+	Comments          []*commentEntry
+	Comment           *models.Comment
+	Collection        *models.Collection
+	Collections       []*models.Collection
+	Team              *models.Team
+	TeamRole          string
+	Teams             []*models.Team
+	Members           []*models.TeamMember
+	Invitation        *models.Invitation
+	InvitationToken   string
 	Snippets          []*models.Snippet
+	TOTPSecret        string
+	TOTPURI           template.URL
+	RecoveryCodes     []string
+	Sessions          []*models.Session
+	CurrentSession    *models.Session
+	Users             []*models.User
+	UserStats         *models.UserStats
+	SnippetStats      *models.SnippetStats
+	Usage             *models.Usage
+	Quota             *quota
+	Import            *importReport
+	Pagination        *pagination
 }
 
 func humanDate(t time.Time) string {
@@ -26,9 +58,59 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// expiresIn describes how long until a snippet expires, e.g. "in 3 hours".
+func expiresIn(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	d := time.Until(t)
+	switch {
+	case d <= 0:
+		return "expired"
+	case d < time.Minute:
+		return "in less than a minute"
+	case d < time.Hour:
+		return "in " + plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return "in " + plural(int(d/time.Hour), "hour")
+	default:
+		return "in " + plural(int(d/(24*time.Hour)), "day")
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// snippetURL returns the link to a snippet, which is built from its slug. The
+// snippets created before slugs existed have none until -backfill-slugs is
+// run, their old numeric link is used instead.
+func snippetURL(s *models.Snippet) string {
+	if s.Slug == "" {
+		return "/snippet/" + strconv.Itoa(s.ID)
+	}
+	return "/s/" + url.PathEscape(s.Slug)
+}
+
 // register template functions
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":       humanDate,
+	"expiresIn":       expiresIn,
+	"snippetURL":      snippetURL,
+	"attachmentURL":   attachmentURL,
+	"fileSize":        fileSize,
+	"commentURL":      commentURL,
+	"collectionURL":   collectionURL,
+	"teamURL":         teamURL,
+	"lines":           lines,
+	"fileAt":          fileAt,
+	"fileInputs":      fileInputs,
+	"languages":       func() []string { return languages },
+	"maxSnippetFiles": func() int { return maxSnippetFiles },
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
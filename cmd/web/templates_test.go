@@ -3,6 +3,8 @@ package main
 import (
 	"testing"
 	"time"
+
+	"dsolerh/snippetbox/pkg/models"
 )
 
 func TestHumanDate(t *testing.T) {
@@ -36,3 +38,45 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestExpiresIn(t *testing.T) {
+	testCases := []struct {
+		desc string
+		tm   time.Time
+		want string
+	}{
+		{"Never", time.Time{}, "never"},
+		{"Expired", time.Now().Add(-time.Minute), "expired"},
+		{"Seconds", time.Now().Add(30 * time.Second), "in less than a minute"},
+		{"Minutes", time.Now().Add(10*time.Minute + time.Second), "in 10 minutes"},
+		{"One hour", time.Now().Add(90 * time.Minute), "in 1 hour"},
+		{"Days", time.Now().Add(3*24*time.Hour + time.Hour), "in 3 days"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := expiresIn(tC.tm)
+			if got != tC.want {
+				t.Errorf("want %q; got %q", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestSnippetURL(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{"Slug", &models.Snippet{ID: 1, Slug: "pond"}, "/s/pond"},
+		{"No slug yet", &models.Snippet{ID: 11}, "/snippet/11"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := snippetURL(tC.snippet)
+			if got != tC.want {
+				t.Errorf("want %q; got %q", tC.want, got)
+			}
+		})
+	}
+}
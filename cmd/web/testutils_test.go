@@ -1,17 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"dsolerh/snippetbox/pkg/blobs"
 	"dsolerh/snippetbox/pkg/models/mock"
 	"html"
 	"io/ioutil"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
 	"testing"
-	"time"
 
 	"github.com/golangcollege/sessions"
 )
@@ -44,7 +46,7 @@ func newTestApplication(t *testing.T) *application {
 
 	// Create a session manager instance, with the same settings as production.
 	session := sessions.New([]byte("3dSm5MnygFHh7XidAtbskXrjbwfoJcbJ"))
-	session.Lifetime = 12 * time.Hour
+	session.Lifetime = sessionLifetime
 	session.Secure = true
 
 	return &application{
@@ -53,10 +55,21 @@ func newTestApplication(t *testing.T) *application {
 		session:       session,
 		snippets:      &mock.SnippetModel{},
 		users:         &mock.UserModel{},
+		sessions:      &mock.SessionModel{},
+		comments:      &mock.CommentModel{},
+		stars:         &mock.StarModel{},
+		collections:   &mock.CollectionModel{},
+		teams:         &mock.TeamModel{},
+		attachments:   &mock.AttachmentModel{},
+		blobs:         &blobs.FS{Dir: t.TempDir()},
 		templateCache: templateCache,
+		unlockLimiter: newAttemptLimiter(maxUnlockAttempts, unlockWindow),
+		totpLimiter:   newAttemptLimiter(maxTOTPAttempts, totpWindow),
 		cfg: &config{
-			Addr:      ":4000",
-			StaticDir: "./ui/static",
+			Addr:           ":4000",
+			StaticDir:      "./ui/static",
+			MaxContentSize: 1 << 20,
+			Quota:          quota{Snippets: 100, Bytes: 1 << 20, Daily: 10},
 		},
 	}
 }
@@ -126,3 +139,72 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	// Return the response status, headers and body.
 	return rs.StatusCode, rs.Header, body
 }
+
+// postMultipart sends a multipart form, with the files given by their field
+// and then their name, like the browsers do when files are uploaded.
+func (ts *testServer) postMultipart(t *testing.T, urlPath string, form url.Values, files map[string]map[string][]byte) (int, http.Header, []byte) {
+	return ts.do(t, ts.multipartRequest(t, urlPath, form, files))
+}
+
+// multipartRequest returns the request postMultipart sends, for the tests
+// which need to change it first.
+func (ts *testServer) multipartRequest(t *testing.T, urlPath string, form url.Values, files map[string]map[string][]byte) *http.Request {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for field, values := range form {
+		for _, value := range values {
+			if err := mw.WriteField(field, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for field, byName := range files {
+		for name, content := range byName {
+			fw, err := mw.CreateFormFile(field, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = fw.Write(content); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// do sends the request with the client of the server.
+func (ts *testServer) do(t *testing.T, req *http.Request) (int, http.Header, []byte) {
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, body
+}
+
+// Create a login method which signs in as one of the mocked users so that
+// subsequent requests made by the test server client are authenticated.
+func (ts *testServer) login(t *testing.T, email string) {
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login: want %d; got %d", http.StatusSeeOther, code)
+	}
+}
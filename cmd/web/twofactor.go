@@ -0,0 +1,235 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"dsolerh/snippetbox/pkg/forms"
+	"dsolerh/snippetbox/pkg/models"
+	"dsolerh/snippetbox/pkg/totp"
+)
+
+const (
+	// the issuer shown next to the account in authenticator apps
+	totpIssuer = "Snippetbox"
+	// number of recovery codes handed out when 2FA is enabled
+	recoveryCodeCount = 10
+	// wrong codes allowed for a user within totpWindow, counted on the
+	// server since the session cookie could be replayed
+	maxTOTPAttempts = 5
+	totpWindow      = 15 * time.Minute
+)
+
+func (app *application) loginTOTPForm(w http.ResponseWriter, r *http.Request) {
+	if !app.session.Exists(r, "pendingUserID") {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	app.render(w, r, "twofactor.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+// loginTOTP is the second step of the login for users with two-factor
+// authentication enabled. The password has already been checked by loginUser
+// which left the id of the user in the session as "pendingUserID"; the
+// user is only really logged in once they provide a valid code.
+func (app *application) loginTOTP(w http.ResponseWriter, r *http.Request) {
+	if !app.session.Exists(r, "pendingUserID") {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+	if !form.Valid() {
+		app.render(w, r, "twofactor.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	id := app.session.GetInt(r, "pendingUserID")
+	if !app.totpLimiter.Allowed(id) {
+		app.session.Remove(r, "pendingUserID")
+		app.session.Put(r, "flash", "Too many invalid codes. Please try again later.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	ok, err := app.checkSecondFactor(id, form.Get("code"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !ok {
+		app.totpLimiter.Fail(id)
+		form.Errors.Add("code", "The code is invalid")
+		app.render(w, r, "twofactor.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	app.totpLimiter.Reset(id)
+	app.session.Remove(r, "pendingUserID")
+	err = app.startSession(r, id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+}
+
+// checkSecondFactor accepts either a code from the authenticator app or one
+// of the user's recovery codes. A code from the app can only be used once,
+// and no code older than the last one used is accepted.
+func (app *application) checkSecondFactor(id int, code string) (bool, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+
+	var err error
+	if len(code) == totp.Digits {
+		var secret string
+		secret, err = app.users.TOTPSecret(id)
+		if err != nil {
+			return false, err
+		}
+		step, ok := totp.ValidateStep(secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		err = app.users.UseTOTPStep(id, step)
+	} else {
+		err = app.users.UseRecoveryCode(id, code)
+	}
+	if err == models.ErrInvalidCredentials {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// setupTOTP generates a new secret for the user and shows it, together with
+// the otpauth:// URI, so it can be added to an authenticator app. The secret
+// is only used at login once it has been confirmed through enableTOTP.
+func (app *application) setupTOTP(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	if user.TOTPEnabled {
+		http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.users.SetTOTPSecret(user.ID, secret)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "totp.page.tmpl", app.totpSetupData(user, secret, forms.New(nil)))
+}
+
+func (app *application) enableTOTP(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	if user.TOTPEnabled {
+		http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	secret, err := app.users.TOTPSecret(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if secret == "" {
+		http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+	if form.Valid() && !totp.Validate(secret, form.Get("code"), time.Now()) {
+		form.Errors.Add("code", "The code is invalid")
+	}
+	if !form.Valid() {
+		app.render(w, r, "totp.page.tmpl", app.totpSetupData(user, secret, form))
+		return
+	}
+
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.users.EnableTOTP(user.ID, codes)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The recovery codes are only stored hashed, so this is the one and only
+	// time the user gets to see them.
+	app.render(w, r, "recovery.page.tmpl", &templateData{
+		RecoveryCodes: codes,
+	})
+}
+
+func (app *application) disableTOTP(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	_, err = app.users.Authenticate(user.Email, r.PostForm.Get("password"))
+	if err == models.ErrInvalidCredentials {
+		app.session.Put(r, "flash", "Password is incorrect, two-factor authentication is still enabled.")
+		http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.users.DisableTOTP(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Two-factor authentication has been disabled.")
+	http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+}
+
+func (app *application) totpSetupData(user *models.User, secret string, form *forms.Form) *templateData {
+	return &templateData{
+		Form:       form,
+		TOTPSecret: secret,
+		// the URI is built by us from a base32 secret, so it's safe to
+		// render as a link
+		TOTPURI: template.URL(totp.URI(secret, totpIssuer, user.Email)),
+	}
+}
@@ -0,0 +1,37 @@
+// Package blobs stores the content of the files attached to snippets, which is
+// kept out of the database.
+package blobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"regexp"
+)
+
+// ErrNotFound is returned when there is no blob with the given key.
+var ErrNotFound = errors.New("blobs: no matching blob found")
+
+// Store is where the blobs are kept. Blobs are identified by a random key
+// chosen when they are stored, and never change afterwards.
+type Store interface {
+	// Put stores everything read from r as a new blob and returns its key.
+	Put(r io.Reader) (string, error)
+	// Open returns the content of the blob, which the caller must close.
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// keyRX matches the keys generated by newKey. Anything else can't be the key
+// of a blob, which keeps them safe to use as file names.
+var keyRX = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// newKey returns a new random key of 32 hex digits.
+func newKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
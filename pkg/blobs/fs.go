@@ -0,0 +1,68 @@
+package blobs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FS stores every blob in a file of its own in Dir, named after its key.
+type FS struct {
+	Dir string
+}
+
+// Put writes the blob to a temporary file first, which is only renamed once
+// it's complete, so a blob can never be read half written.
+func (s *FS) Put(r io.Reader) (string, error) {
+	key, err := newKey()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(s.Dir, "upload-*")
+	if err != nil {
+		return "", err
+	}
+	// Removing the temporary file fails once it has been renamed, which is
+	// expected.
+	defer os.Remove(f.Name())
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+
+	if err = os.Rename(f.Name(), filepath.Join(s.Dir, key)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *FS) Open(key string) (io.ReadCloser, error) {
+	if !keyRX.MatchString(key) {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(filepath.Join(s.Dir, key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *FS) Delete(key string) error {
+	if !keyRX.MatchString(key) {
+		return ErrNotFound
+	}
+
+	err := os.Remove(filepath.Join(s.Dir, key))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package blobs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFS(t *testing.T) {
+	s := &FS{Dir: t.TempDir()}
+
+	key, err := s.Put(strings.NewReader("pond: old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !keyRX.MatchString(key) {
+		t.Errorf("want a key of 32 hex digits; got %q", key)
+	}
+
+	rc, err := s.Open(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "pond: old" {
+		t.Errorf("want %q; got %q", "pond: old", content)
+	}
+
+	// only the blob is left behind, not the temporary file
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != key {
+		t.Errorf("want a single file named after the key; got %v", entries)
+	}
+
+	if err = s.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Open(key); err != ErrNotFound {
+		t.Errorf("want ErrNotFound once deleted; got %v", err)
+	}
+	if err = s.Delete(key); err != ErrNotFound {
+		t.Errorf("want ErrNotFound deleting twice; got %v", err)
+	}
+}
+
+func TestFSInvalidKey(t *testing.T) {
+	dir := t.TempDir()
+	s := &FS{Dir: filepath.Join(dir, "blobs")}
+	if err := os.Mkdir(s.Dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	// a file outside of the store, which no key must reach
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "../secret", "0123456789ABCDEF0123456789ABCDEF", "upload-123"} {
+		if _, err := s.Open(key); err != ErrNotFound {
+			t.Errorf("opening %q: want ErrNotFound; got %v", key, err)
+		}
+		if err := s.Delete(key); err != ErrNotFound {
+			t.Errorf("deleting %q: want ErrNotFound; got %v", key, err)
+		}
+	}
+}
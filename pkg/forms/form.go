@@ -1,7 +1,12 @@
 package forms
 
 import (
+	"encoding/base64"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
@@ -12,13 +17,15 @@ var EmailRX = regexp.MustCompile(`^[a-zA-Z0-9.]+@[a-zA-Z0-9]+\.[a-zA-Z]+$`)
 
 type Form struct {
 	url.Values
+	// Files are the files uploaded with a multipart form, nil otherwise.
+	Files  map[string][]*multipart.FileHeader
 	Errors errors
 }
 
 func New(data url.Values) *Form {
 	return &Form{
-		data,
-		errors(map[string][]string{}),
+		Values: data,
+		Errors: errors(map[string][]string{}),
 	}
 }
 
@@ -74,6 +81,100 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 	}
 }
 
+// Base64 checks that the field holds standard base64 data which decodes to
+// between min and max bytes.
+func (f *Form) Base64(field string, min, max int) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	if base64.StdEncoding.EncodedLen(max) < len(value) {
+		f.Errors.Add(field, fmt.Sprintf("This field is too long (maximum is %d bytes)", max))
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		f.Errors.Add(field, "This field is invalid")
+		return
+	}
+	if len(b) < min {
+		f.Errors.Add(field, fmt.Sprintf("This field is too short (minimun is %d bytes)", min))
+	} else if len(b) > max {
+		f.Errors.Add(field, fmt.Sprintf("This field is too long (maximum is %d bytes)", max))
+	}
+}
+
+// MaxBytes checks that the values of the field, all of them when the field is
+// repeated, are no longer than max bytes together.
+func (f *Form) MaxBytes(field string, max int) {
+	n := 0
+	for _, value := range f.Values[field] {
+		n += len(value)
+	}
+	if n > max {
+		f.Errors.Add(field, fmt.Sprintf("This field is too long (maximum is %d bytes)", max))
+	}
+}
+
+// MaxFiles checks that no more than max files were uploaded in the field.
+func (f *Form) MaxFiles(field string, max int) {
+	if len(f.Files[field]) > max {
+		f.Errors.Add(field, fmt.Sprintf("Too many files (maximum is %d)", max))
+	}
+}
+
+// MaxFileSize checks that none of the files uploaded in the field is bigger
+// than max bytes.
+func (f *Form) MaxFileSize(field string, max int64) {
+	for _, fh := range f.Files[field] {
+		if fh.Size > max {
+			f.Errors.Add(field, fmt.Sprintf("%s is too big (maximum is %d bytes)", fh.Filename, max))
+		}
+	}
+}
+
+// PermittedFileTypes checks that the files uploaded in the field are of one
+// of the media types, e.g. "image/png". The type is sniffed from their
+// content, whatever the client claims they are.
+func (f *Form) PermittedFileTypes(field string, types ...string) {
+	for _, fh := range f.Files[field] {
+		ct, err := FileType(fh)
+		if err != nil {
+			f.Errors.Add(field, fmt.Sprintf("%s can't be read", fh.Filename))
+			continue
+		}
+		mediaType, _, _ := mime.ParseMediaType(ct)
+		permitted := false
+		for _, t := range types {
+			if mediaType == t {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			f.Errors.Add(field, fmt.Sprintf("%s is of a type which isn't allowed", fh.Filename))
+		}
+	}
+}
+
+// FileType returns the content type of an uploaded file, sniffed from its
+// first bytes with http.DetectContentType.
+func FileType(fh *multipart.FileHeader) (string, error) {
+	file, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// DetectContentType considers at most the first 512 bytes
+	b := make([]byte, 512)
+	n, err := io.ReadFull(file, b)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(b[:n]), nil
+}
+
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
 }
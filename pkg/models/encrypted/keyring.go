@@ -0,0 +1,162 @@
+// Package encrypted encrypts the content of the snippets before it's stored,
+// so it can't be read from the database or its backups without the keys.
+//
+// It uses envelope encryption: every snippet is sealed with its own random
+// data key, and the data key is sealed with one of the master keys of a
+// Keyring. The id of the master key is stored next to the content, so the
+// master keys can be rotated without having to re-encrypt everything at once.
+package encrypted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size in bytes of the master and data keys, AES-256.
+const KeySize = 32
+
+// maxKeyIDLength is the size of the key_id column.
+const maxKeyIDLength = 16
+
+var (
+	ErrUnknownKey = errors.New("encrypted: unknown key")
+	ErrMalformed  = errors.New("encrypted: malformed envelope")
+)
+
+// Keyring holds the master keys by id. The primary key encrypts new content,
+// the other ones are only kept to decrypt content from before a rotation.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring parses a comma separated list of id:key pairs, where each key
+// is 32 random bytes encoded in base64. The first key is the primary one.
+func ParseKeyring(s string) (*Keyring, error) {
+	k := &Keyring{keys: map[string]cipher.AEAD{}}
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		i := strings.Index(pair, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("encrypted: key %q isn't in the id:key format", pair)
+		}
+		id, encoded := pair[:i], pair[i+1:]
+		if len(id) > maxKeyIDLength {
+			return nil, fmt.Errorf("encrypted: key id %q is longer than %d characters", id, maxKeyIDLength)
+		}
+		if _, ok := k.keys[id]; ok {
+			return nil, fmt.Errorf("encrypted: duplicate key id %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("encrypted: key %q must be %d bytes encoded in base64", id, KeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		k.keys[id] = aead
+		if k.primary == "" {
+			k.primary = id
+		}
+	}
+	return k, nil
+}
+
+// Primary returns the id of the key used to encrypt new content.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Encrypt seals the plaintext with a new data key, seals the data key with
+// the primary key and returns both in a base64 envelope, together with the id
+// of the primary key which is needed to open it.
+func (k *Keyring) Encrypt(plaintext []byte) (envelope, keyID string, err error) {
+	dataKey := make([]byte, KeySize)
+	if _, err = rand.Read(dataKey); err != nil {
+		return "", "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	// The key id is authenticated with the data key, so an envelope can't be
+	// passed off as belonging to another key.
+	sealedKey, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return "", "", err
+	}
+	sealedContent, err := seal(dataAEAD, plaintext, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	b := append(sealedKey, sealedContent...)
+	return base64.StdEncoding.EncodeToString(b), k.primary, nil
+}
+
+// Decrypt opens an envelope returned by Encrypt with the key it was sealed
+// with.
+func (k *Keyring) Decrypt(envelope, keyID string) ([]byte, error) {
+	master, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	b, err := base64.StdEncoding.DecodeString(envelope)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	sealedKeyLength := master.NonceSize() + KeySize + master.Overhead()
+	if len(b) < sealedKeyLength {
+		return nil, ErrMalformed
+	}
+
+	dataKey, err := open(master, b[:sealedKeyLength], []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataAEAD, b[sealedKeyLength:], nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext with a random nonce, which is put in front of
+// the ciphertext.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, b, additionalData []byte) ([]byte, error) {
+	if len(b) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
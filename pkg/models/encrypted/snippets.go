@@ -0,0 +1,226 @@
+package encrypted
+
+import (
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// SnippetModel wraps another ISnippetModel, usually the mysql one, encrypting
+// the content of the files of the snippets it stores and decrypting the
+// content of the files of the snippets it returns. The methods which don't
+// deal with the content are passed through as they are.
+type SnippetModel struct {
+	models.ISnippetModel
+	Keys *Keyring
+}
+
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	files := s.Files
+	encrypted, keyID, err := encryptFiles(m.Keys, files)
+	if err != nil {
+		return 0, err
+	}
+
+	s.Files, s.KeyID = encrypted, keyID
+	id, err := m.ISnippetModel.Insert(s)
+	// the caller still gets the snippet it gave us
+	s.Files = files
+	return id, err
+}
+
+func (m *SnippetModel) InsertMany(snippets []*models.Snippet) error {
+	encrypted := make([][]*models.File, len(snippets))
+	var keyID string
+	for i, s := range snippets {
+		var err error
+		if encrypted[i], keyID, err = encryptFiles(m.Keys, s.Files); err != nil {
+			return err
+		}
+	}
+
+	files := make([][]*models.File, len(snippets))
+	for i, s := range snippets {
+		files[i] = s.Files
+		s.Files, s.KeyID = encrypted[i], keyID
+	}
+	err := m.ISnippetModel.InsertMany(snippets)
+	for i, s := range snippets {
+		s.Files = files[i]
+	}
+	return err
+}
+
+func (m *SnippetModel) Update(s *models.Snippet, editorID int) error {
+	files := s.Files
+	encrypted, keyID, err := encryptFiles(m.Keys, files)
+	if err != nil {
+		return err
+	}
+
+	s.Files, s.KeyID = encrypted, keyID
+	err = m.ISnippetModel.Update(s, editorID)
+	s.Files = files
+	return err
+}
+
+// encryptFiles returns copies of the files with their content encrypted with
+// the primary key, and the id of that key.
+func encryptFiles(keys *Keyring, files []*models.File) ([]*models.File, string, error) {
+	encrypted := make([]*models.File, len(files))
+	for i, f := range files {
+		envelope, _, err := keys.Encrypt([]byte(f.Content))
+		if err != nil {
+			return nil, "", err
+		}
+		encrypted[i] = &models.File{Name: f.Name, Language: f.Language, Content: envelope}
+	}
+	// Encrypt always seals with the primary key
+	return encrypted, keys.Primary(), nil
+}
+
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	return m.decrypt(m.ISnippetModel.Get(id))
+}
+
+func (m *SnippetModel) GetBySlug(slug string, viewerID int) (*models.Snippet, error) {
+	return m.decrypt(m.ISnippetModel.GetBySlug(slug, viewerID))
+}
+
+func (m *SnippetModel) GetVisible(id, viewerID int) (*models.Snippet, error) {
+	return m.decrypt(m.ISnippetModel.GetVisible(id, viewerID))
+}
+
+func (m *SnippetModel) Forks(id, viewerID int) ([]*models.Snippet, error) {
+	return m.decryptAll(m.ISnippetModel.Forks(id, viewerID))
+}
+
+func (m *SnippetModel) Burn(slug string, viewerID int) (*models.Snippet, error) {
+	return m.decrypt(m.ISnippetModel.Burn(slug, viewerID))
+}
+
+func (m *SnippetModel) Latest(sort string) ([]*models.Snippet, error) {
+	return m.decryptAll(m.ISnippetModel.Latest(sort))
+}
+
+func (m *SnippetModel) LatestForUser(userID int) ([]*models.Snippet, error) {
+	return m.decryptAll(m.ISnippetModel.LatestForUser(userID))
+}
+
+func (m *SnippetModel) StarredBy(userID int) ([]*models.Snippet, error) {
+	return m.decryptAll(m.ISnippetModel.StarredBy(userID))
+}
+
+func (m *SnippetModel) ForUser(userID int) ([]*models.Snippet, error) {
+	return m.decryptAll(m.ISnippetModel.ForUser(userID))
+}
+
+func (m *SnippetModel) EachForUser(userID int, fn func(*models.Snippet) error) error {
+	return m.ISnippetModel.EachForUser(userID, func(s *models.Snippet) error {
+		s, err := m.decrypt(s, nil)
+		if err != nil {
+			return err
+		}
+		return fn(s)
+	})
+}
+
+func (m *SnippetModel) InCollection(collectionID, viewerID int) ([]*models.Snippet, error) {
+	return m.decryptAll(m.ISnippetModel.InCollection(collectionID, viewerID))
+}
+
+func (m *SnippetModel) ForTeam(teamID int) ([]*models.Snippet, error) {
+	return m.decryptAll(m.ISnippetModel.ForTeam(teamID))
+}
+
+func (m *SnippetModel) List(f models.SnippetFilter) ([]*models.Snippet, int, error) {
+	snippets, total, err := m.ISnippetModel.List(f)
+	snippets, err = m.decryptAll(snippets, err)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, total, nil
+}
+
+// decrypt replaces the content of the files of the snippet with their
+// plaintext. Snippets without a key id were stored before encryption was
+// turned on and are returned as they are.
+func (m *SnippetModel) decrypt(s *models.Snippet, err error) (*models.Snippet, error) {
+	if err != nil {
+		return nil, err
+	}
+	if s.KeyID == "" {
+		return s, nil
+	}
+
+	for _, f := range s.Files {
+		plaintext, err := m.Keys.Decrypt(f.Content, s.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		f.Content = string(plaintext)
+	}
+	return s, nil
+}
+
+func (m *SnippetModel) decryptAll(snippets []*models.Snippet, err error) ([]*models.Snippet, error) {
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snippets {
+		if _, err = m.decrypt(s, nil); err != nil {
+			return nil, err
+		}
+	}
+	return snippets, nil
+}
+
+// reencryptBatchSize is how many snippets Reencrypt reads at a time.
+const reencryptBatchSize = 100
+
+// ContentStore gives raw access to the stored content, for Reencrypt. It's
+// implemented by mysql.SnippetModel.
+type ContentStore interface {
+	// StaleContent returns up to limit snippets, with only their id, key id
+	// and files, which weren't encrypted with the given key.
+	StaleContent(keyID string, limit int) ([]*models.Snippet, error)
+	// SetContent replaces the content of the files of the snippet, given in
+	// the same order.
+	SetContent(id int, files []*models.File, keyID string) error
+}
+
+// Reencrypt encrypts with the primary key every snippet which was encrypted
+// with an older key, or not encrypted at all, and returns how many were
+// updated. Once it's done the older keys can be removed from the keyring.
+func Reencrypt(store ContentStore, keys *Keyring) (int, error) {
+	n := 0
+	for {
+		snippets, err := store.StaleContent(keys.Primary(), reencryptBatchSize)
+		if err != nil {
+			return n, err
+		}
+		if len(snippets) == 0 {
+			return n, nil
+		}
+
+		for _, s := range snippets {
+			for _, f := range s.Files {
+				if s.KeyID == "" {
+					continue
+				}
+				plaintext, err := keys.Decrypt(f.Content, s.KeyID)
+				if err != nil {
+					return n, err
+				}
+				f.Content = string(plaintext)
+			}
+
+			files, keyID, err := encryptFiles(keys, s.Files)
+			if err != nil {
+				return n, err
+			}
+			if err = store.SetContent(s.ID, files, keyID); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package encrypted
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"dsolerh/snippetbox/pkg/models"
+	"dsolerh/snippetbox/pkg/models/mock"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), KeySize)))
+}
+
+func newTestKeyring(t *testing.T, s string) *Keyring {
+	k, err := ParseKeyring(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// memoryStore keeps the snippets in memory, as they would be stored in the
+// database, on top of the mock model.
+type memoryStore struct {
+	mock.SnippetModel
+	snippets map[int]*models.Snippet
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{snippets: map[int]*models.Snippet{}}
+}
+
+func (m *memoryStore) Insert(s *models.Snippet) (int, error) {
+	s.ID = len(m.snippets) + 1
+	m.snippets[s.ID] = copySnippet(s)
+	return s.ID, nil
+}
+
+func (m *memoryStore) InsertMany(snippets []*models.Snippet) error {
+	for _, s := range snippets {
+		m.Insert(s)
+	}
+	return nil
+}
+
+// copySnippet copies the snippet and its files, so changes made to either
+// copy don't affect the other one.
+func copySnippet(s *models.Snippet) *models.Snippet {
+	c := *s
+	c.Files = make([]*models.File, len(s.Files))
+	for i, f := range s.Files {
+		file := *f
+		c.Files[i] = &file
+	}
+	return &c
+}
+
+func (m *memoryStore) Update(s *models.Snippet, editorID int) error {
+	if _, ok := m.snippets[s.ID]; !ok {
+		return models.ErrNoRecord
+	}
+	m.snippets[s.ID] = copySnippet(s)
+	return nil
+}
+
+func (m *memoryStore) Get(id int) (*models.Snippet, error) {
+	s, ok := m.snippets[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return copySnippet(s), nil
+}
+
+func (m *memoryStore) StaleContent(keyID string, limit int) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+	for id := 1; id <= len(m.snippets) && len(snippets) < limit; id++ {
+		if s := m.snippets[id]; s.KeyID != keyID {
+			stale := copySnippet(s)
+			snippets = append(snippets, &models.Snippet{ID: stale.ID, Files: stale.Files, KeyID: stale.KeyID})
+		}
+	}
+	return snippets, nil
+}
+
+func (m *memoryStore) SetContent(id int, files []*models.File, keyID string) error {
+	for i, f := range files {
+		m.snippets[id].Files[i].Content = f.Content
+	}
+	m.snippets[id].KeyID = keyID
+	return nil
+}
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		wantErr bool
+	}{
+		{"Single key", "k1:" + testKey('a'), false},
+		{"Several keys", "k2:" + testKey('b') + ", k1:" + testKey('a'), false},
+		{"Missing id", ":" + testKey('a'), true},
+		{"Missing key", "k1", true},
+		{"Short key", "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), true},
+		{"Not base64", "k1:not a key", true},
+		{"Duplicate id", "k1:" + testKey('a') + ",k1:" + testKey('b'), true},
+		{"Long id", strings.Repeat("k", 17) + ":" + testKey('a'), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeyring(tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	keys := newTestKeyring(t, "k2:"+testKey('b')+",k1:"+testKey('a'))
+
+	envelope, keyID, err := keys.Encrypt([]byte("An old silent pond..."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "k2" {
+		t.Errorf("want the primary key k2; got %q", keyID)
+	}
+	if strings.Contains(envelope, "pond") {
+		t.Errorf("want the envelope to not contain the plaintext")
+	}
+
+	plaintext, err := keys.Decrypt(envelope, keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "An old silent pond..." {
+		t.Errorf("want %q; got %q", "An old silent pond...", plaintext)
+	}
+
+	// The same key under another id must not open the envelope.
+	other := newTestKeyring(t, "k1:"+testKey('b'))
+	if _, err := other.Decrypt(envelope, "k1"); err != ErrMalformed {
+		t.Errorf("want ErrMalformed for the wrong key id; got %v", err)
+	}
+	if _, err := keys.Decrypt(envelope, "k3"); err != ErrUnknownKey {
+		t.Errorf("want ErrUnknownKey; got %v", err)
+	}
+
+	b, _ := base64.StdEncoding.DecodeString(envelope)
+	b[len(b)-1] ^= 1
+	if _, err := keys.Decrypt(base64.StdEncoding.EncodeToString(b), keyID); err != ErrMalformed {
+		t.Errorf("want ErrMalformed for a tampered envelope; got %v", err)
+	}
+}
+
+func TestSnippetModel(t *testing.T) {
+	store := newMemoryStore()
+	m := &SnippetModel{ISnippetModel: store, Keys: newTestKeyring(t, "k1:"+testKey('a'))}
+
+	s := &models.Snippet{Title: "O snail", Files: []*models.File{
+		{Name: "snail.txt", Content: "Climb Mount Fuji"},
+		{Name: "fuji.txt", Content: "But slowly, slowly"},
+	}}
+	id, err := m.Insert(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Files[0].Content != "Climb Mount Fuji" {
+		t.Errorf("want the inserted snippet to keep its plaintext; got %q", s.Files[0].Content)
+	}
+	stored := store.snippets[id]
+	if stored.KeyID != "k1" || stored.Files[1].Name != "fuji.txt" {
+		t.Errorf("want the files to be stored with key k1; got key %q", stored.KeyID)
+	}
+	for _, f := range stored.Files {
+		if strings.Contains(f.Content, "low") || strings.Contains(f.Content, "Fuji") {
+			t.Errorf("want the content to be stored encrypted; got %q", f.Content)
+		}
+	}
+
+	got, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Files[0].Content != "Climb Mount Fuji" || got.Files[1].Content != "But slowly, slowly" {
+		t.Errorf("want the plaintext of both files; got %q and %q", got.Files[0].Content, got.Files[1].Content)
+	}
+
+	s.Files = []*models.File{{Name: "snail.txt", Content: "Slowly, slowly"}}
+	if err = m.Update(s, s.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if stored := store.snippets[id]; len(stored.Files) != 1 || strings.Contains(stored.Files[0].Content, "lowly") {
+		t.Errorf("want the updated content to be stored encrypted; got %d files", len(stored.Files))
+	}
+	if got, _ = m.Get(id); got.Files[0].Content != "Slowly, slowly" {
+		t.Errorf("want %q; got %q", "Slowly, slowly", got.Files[0].Content)
+	}
+
+	// Snippets stored before encryption was turned on are left alone.
+	got, err = m.GetBySlug("pond", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Files[0].Content != "An old silent pond..." {
+		t.Errorf("want the plaintext snippet as it is; got %q", got.Files[0].Content)
+	}
+}
+
+func TestSnippetModelInsertMany(t *testing.T) {
+	store := newMemoryStore()
+	m := &SnippetModel{ISnippetModel: store, Keys: newTestKeyring(t, "k1:"+testKey('a'))}
+
+	snippets := []*models.Snippet{
+		{Title: "Pond", Files: []*models.File{{Name: "pond.txt", Content: "An old silent pond"}}},
+		{Title: "Frog", Files: []*models.File{{Name: "frog.txt", Content: "A frog jumps into the pond"}}},
+	}
+	if err := m.InsertMany(snippets); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range snippets {
+		stored := store.snippets[s.ID]
+		if stored.KeyID != "k1" || strings.Contains(stored.Files[0].Content, "pond") {
+			t.Errorf("want %s to be stored encrypted with key k1; got key %q", s.Title, stored.KeyID)
+		}
+		if !strings.Contains(s.Files[0].Content, "pond") {
+			t.Errorf("want %s to keep its plaintext; got %q", s.Title, s.Files[0].Content)
+		}
+	}
+}
+
+func TestReencrypt(t *testing.T) {
+	store := newMemoryStore()
+	old := &SnippetModel{ISnippetModel: store, Keys: newTestKeyring(t, "k1:"+testKey('a'))}
+	old.Insert(&models.Snippet{Files: []*models.File{{Content: "first"}, {Content: "again"}}})
+	store.Insert(&models.Snippet{Files: []*models.File{{Content: "second"}}})
+
+	// After a rotation both keys are in the keyring until Reencrypt is done.
+	keys := newTestKeyring(t, "k2:"+testKey('b')+",k1:"+testKey('a'))
+	n, err := Reencrypt(store, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want 2 snippets re-encrypted; got %d", n)
+	}
+
+	m := &SnippetModel{ISnippetModel: store, Keys: newTestKeyring(t, "k2:"+testKey('b'))}
+	for id, want := range map[int]string{1: "first", 2: "second"} {
+		s, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.KeyID != "k2" || s.Files[0].Content != want {
+			t.Errorf("want %q with key k2; got %q with key %q", want, s.Files[0].Content, s.KeyID)
+		}
+	}
+
+	if n, _ = Reencrypt(store, keys); n != 0 {
+		t.Errorf("want nothing left to re-encrypt; got %d", n)
+	}
+}
@@ -1,13 +1,105 @@
 package models
 
+import "time"
+
 type ISnippetModel interface {
-	Insert(string, string, string) (int, error)
+	Insert(*Snippet) (int, error)
+	InsertMany([]*Snippet) error
+	Update(*Snippet, int) error
 	Get(int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	GetBySlug(string, int) (*Snippet, error)
+	GetVisible(int, int) (*Snippet, error)
+	Forks(int, int) ([]*Snippet, error)
+	Burn(string, int) (*Snippet, error)
+	CheckPassword(int, string) error
+	Latest(string) ([]*Snippet, error)
+	LatestForUser(int) ([]*Snippet, error)
+	ForUser(int) ([]*Snippet, error)
+	EachForUser(int, func(*Snippet) error) error
+	StarredBy(int) ([]*Snippet, error)
+	InCollection(int, int) ([]*Snippet, error)
+	ForTeam(int) ([]*Snippet, error)
+	List(SnippetFilter) ([]*Snippet, int, error)
+	Stats() (*SnippetStats, error)
+	Usage(int) (*Usage, error)
+	Delete(int) error
+	Expire(int) error
+	DeleteExpired() (int, error)
+}
+
+type IAttachmentModel interface {
+	Get(int) (*Attachment, error)
+	ForSnippet(int) ([]*Attachment, error)
+	Orphans() ([]*Attachment, error)
+	Delete(int) error
 }
 
 type IUserModel interface {
 	Insert(string, string, string) error
 	Authenticate(string, string) (int, error)
 	Get(int) (*User, error)
+	GetByEmail(string) (*User, error)
+	SetRole(int, string) error
+	SetSuspended(int, bool) error
+	List(UserFilter) ([]*User, int, error)
+	Stats() (*UserStats, error)
+	Delete(int) error
+	SetTOTPSecret(int, string) error
+	TOTPSecret(int) (string, error)
+	EnableTOTP(int, []string) error
+	DisableTOTP(int) error
+	UseRecoveryCode(int, string) error
+	UseTOTPStep(int, int64) error
+}
+
+type ISessionModel interface {
+	Create(int, string, string, time.Duration) (string, error)
+	Get(string) (*Session, error)
+	Touch(int) error
+	ForUser(int) ([]*Session, error)
+	Delete(string) error
+	Revoke(int, int) error
+	RevokeOthers(int, int) error
+	DeleteExpired() (int, error)
+}
+
+type IStarModel interface {
+	Star(int, int) error
+	Unstar(int, int) error
+	Starred(int, int) (bool, error)
+}
+
+type ICollectionModel interface {
+	Insert(*Collection) (int, error)
+	GetBySlug(string, int) (*Collection, error)
+	ForUser(int) ([]*Collection, error)
+	Rename(int, int, string) error
+	SetPublic(int, int, bool) error
+	Delete(int, int) error
+	AddSnippet(int, int, int) error
+	RemoveSnippet(int, int, int) error
+	MoveSnippet(int, int, int, int) error
+}
+
+type ICommentModel interface {
+	Insert(*Comment) (int, error)
+	Get(int) (*Comment, error)
+	ForSnippet(int) ([]*Comment, error)
+	ForUser(int) ([]*Comment, error)
+	Update(int, int, string) error
+	Delete(int, int) error
+}
+
+type ITeamModel interface {
+	Insert(*Team, int) (int, error)
+	GetBySlug(string) (*Team, error)
+	ForUser(int) ([]*Team, error)
+	Role(int, int) (string, error)
+	Members(int) ([]*TeamMember, error)
+	SetRole(int, int, string) error
+	RemoveMember(int, int) error
+	Invite(int, string, int) (string, error)
+	Invitation(string) (*Invitation, error)
+	Accept(string, int) error
+	Decline(string) error
 }
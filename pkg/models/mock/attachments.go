@@ -0,0 +1,77 @@
+package mock
+
+import (
+	"dsolerh/snippetbox/pkg/models"
+	"time"
+)
+
+// MockBlobKey is the key of the blob holding the content of the mocked
+// attachment of the pond snippet.
+const MockBlobKey = "0123456789abcdef0123456789abcdef"
+
+var mockAttachment = &models.Attachment{
+	ID:          1,
+	SnippetID:   1,
+	Name:        "config.yaml",
+	ContentType: "text/plain; charset=utf-8",
+	Size:        int64(len("pond: old\nsilent: true\n")),
+	Key:         MockBlobKey,
+	Created:     time.Now(),
+}
+
+var mockUnlistedAttachment = &models.Attachment{
+	ID:          2,
+	SnippetID:   4,
+	Name:        "notes.txt",
+	ContentType: "text/plain; charset=utf-8",
+	Size:        5,
+	Key:         "fedcba9876543210fedcba9876543210",
+	Created:     time.Now(),
+}
+
+var mockOrphanAttachment = &models.Attachment{
+	ID:          3,
+	SnippetID:   42,
+	Name:        "gone.png",
+	ContentType: "image/png",
+	Size:        8,
+	Key:         "00000000000000000000000000000000",
+	Created:     time.Now(),
+}
+
+type AttachmentModel struct{}
+
+func (m *AttachmentModel) Get(id int) (*models.Attachment, error) {
+	switch id {
+	case 1:
+		return mockAttachment, nil
+	case 2:
+		return mockUnlistedAttachment, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *AttachmentModel) ForSnippet(snippetID int) ([]*models.Attachment, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Attachment{mockAttachment}, nil
+	case 4:
+		return []*models.Attachment{mockUnlistedAttachment}, nil
+	default:
+		return []*models.Attachment{}, nil
+	}
+}
+
+func (m *AttachmentModel) Orphans() ([]*models.Attachment, error) {
+	return []*models.Attachment{mockOrphanAttachment}, nil
+}
+
+func (m *AttachmentModel) Delete(id int) error {
+	switch id {
+	case 1, 2, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
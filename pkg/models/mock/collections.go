@@ -0,0 +1,95 @@
+package mock
+
+import (
+	"dsolerh/snippetbox/pkg/models"
+	"time"
+)
+
+var mockCollection = &models.Collection{
+	ID:      1,
+	Slug:    "haiku",
+	UserID:  1,
+	Name:    "Haiku",
+	Public:  true,
+	Created: time.Now(),
+}
+
+var mockPrivateCollection = &models.Collection{
+	ID:      2,
+	Slug:    "drafts",
+	UserID:  1,
+	Name:    "Drafts",
+	Public:  false,
+	Created: time.Now(),
+}
+
+type CollectionModel struct{}
+
+func (m *CollectionModel) Insert(c *models.Collection) (int, error) {
+	c.ID, c.Slug = 3, "new"
+	return c.ID, nil
+}
+
+func (m *CollectionModel) GetBySlug(slug string, viewerID int) (*models.Collection, error) {
+	var c *models.Collection
+	switch slug {
+	case "haiku":
+		c = mockCollection
+	case "drafts":
+		c = mockPrivateCollection
+	default:
+		return nil, models.ErrNoRecord
+	}
+
+	if !c.Public && c.UserID != viewerID {
+		return nil, models.ErrNoRecord
+	}
+	return c, nil
+}
+
+func (m *CollectionModel) ForUser(userID int) ([]*models.Collection, error) {
+	switch userID {
+	case 1:
+		return []*models.Collection{mockPrivateCollection, mockCollection}, nil
+	default:
+		return []*models.Collection{}, nil
+	}
+}
+
+func (m *CollectionModel) Rename(id, userID int, name string) error {
+	return m.checkOwner(id, userID)
+}
+
+func (m *CollectionModel) SetPublic(id, userID int, public bool) error {
+	return m.checkOwner(id, userID)
+}
+
+func (m *CollectionModel) Delete(id, userID int) error {
+	return m.checkOwner(id, userID)
+}
+
+func (m *CollectionModel) AddSnippet(id, userID, snippetID int) error {
+	return m.checkOwner(id, userID)
+}
+
+func (m *CollectionModel) RemoveSnippet(id, userID, snippetID int) error {
+	if err := m.checkOwner(id, userID); err != nil {
+		return err
+	}
+	if id != 1 || snippetID != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CollectionModel) MoveSnippet(id, userID, snippetID, delta int) error {
+	return m.RemoveSnippet(id, userID, snippetID)
+}
+
+// both mock collections belong to alice
+func (m *CollectionModel) checkOwner(id, userID int) error {
+	if (id != 1 && id != 2) || userID != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
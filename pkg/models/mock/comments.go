@@ -0,0 +1,78 @@
+package mock
+
+import (
+	"dsolerh/snippetbox/pkg/models"
+	"time"
+)
+
+var mockComment = &models.Comment{
+	ID:        1,
+	SnippetID: 1,
+	UserID:    1,
+	UserName:  "Alice",
+	Content:   "A lovely haiku",
+	Created:   time.Now(),
+}
+
+var mockReply = &models.Comment{
+	ID:        2,
+	SnippetID: 1,
+	UserID:    2,
+	UserName:  "Tom",
+	ParentID:  1,
+	Line:      1,
+	Content:   "The frog is my favourite part",
+	Created:   time.Now(),
+}
+
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(c *models.Comment) (int, error) {
+	c.ID = 3
+	return c.ID, nil
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	switch id {
+	case 1:
+		return mockComment, nil
+	case 2:
+		return mockReply, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Comment{mockComment, mockReply}, nil
+	default:
+		return []*models.Comment{}, nil
+	}
+}
+
+func (m *CommentModel) ForUser(userID int) ([]*models.Comment, error) {
+	switch userID {
+	case 1:
+		return []*models.Comment{mockComment}, nil
+	default:
+		return []*models.Comment{}, nil
+	}
+}
+
+func (m *CommentModel) Update(id, userID int, content string) error {
+	c, err := m.Get(id)
+	if err != nil || c.UserID != userID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CommentModel) Delete(id, userID int) error {
+	c, err := m.Get(id)
+	if err != nil || c.UserID != userID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
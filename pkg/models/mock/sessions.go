@@ -0,0 +1,104 @@
+package mock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// SessionModel keeps the sessions in memory. Unlike the other mocks it needs
+// some state, otherwise a login could never be followed by an authenticated
+// request.
+type SessionModel struct {
+	mu       sync.Mutex
+	nextID   int
+	sessions map[string]*models.Session
+}
+
+func (m *SessionModel) Create(userID int, ip, userAgent string, lifetime time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sessions == nil {
+		m.sessions = map[string]*models.Session{}
+	}
+	m.nextID++
+	token := fmt.Sprintf("token-%d", m.nextID)
+	m.sessions[token] = &models.Session{
+		ID:        m.nextID,
+		UserID:    userID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Created:   time.Now(),
+		LastSeen:  time.Now(),
+		Expires:   time.Now().Add(lifetime),
+	}
+	return token, nil
+}
+
+func (m *SessionModel) Get(token string) (*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[token]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return s, nil
+}
+
+func (m *SessionModel) Touch(id int) error {
+	return nil
+}
+
+func (m *SessionModel) ForUser(userID int) ([]*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := []*models.Session{}
+	for _, s := range m.sessions {
+		if s.UserID == userID {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *SessionModel) Delete(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, token)
+	return nil
+}
+
+func (m *SessionModel) Revoke(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, s := range m.sessions {
+		if s.ID == id && s.UserID == userID {
+			delete(m.sessions, token)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SessionModel) RevokeOthers(userID, keepID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, s := range m.sessions {
+		if s.UserID == userID && s.ID != keepID {
+			delete(m.sessions, token)
+		}
+	}
+	return nil
+}
+
+func (m *SessionModel) DeleteExpired() (int, error) {
+	return 0, nil
+}
@@ -2,32 +2,383 @@ package mock
 
 import (
 	"dsolerh/snippetbox/pkg/models"
+	"errors"
+	"fmt"
 	"time"
 )
 
+// ErrMockLostConnection is returned by EachForUser for Ada once the first
+// snippet has been read.
+var ErrMockLostConnection = errors.New("mock: lost the connection to the database")
+
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:          1,
+	Slug:        "pond",
+	UserID:      1,
+	Title:       "An old silent pond",
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Visibility:  models.VisibilityPublic,
+	ContentType: models.ContentTypeText,
+	Files: []*models.File{
+		{Name: "pond.txt", Content: "An old silent pond..."},
+		{Name: "frog.txt", Content: "A frog jumps into the pond,\nsplash! Silence again."},
+	},
+}
+
+var mockPrivateSnippet = &models.Snippet{
+	ID:          3,
+	Slug:        "private",
+	UserID:      1,
+	Title:       "A private haiku",
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Visibility:  models.VisibilityPrivate,
+	ContentType: models.ContentTypeText,
+	Files:       []*models.File{{Name: "private.txt", Content: "Only Alice can read this..."}},
+}
+
+var mockUnlistedSnippet = &models.Snippet{
+	ID:          4,
+	Slug:        "unlisted",
+	UserID:      1,
+	Title:       "An unlisted haiku",
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Visibility:  models.VisibilityUnlisted,
+	ContentType: models.ContentTypeText,
+	Files:       []*models.File{{Name: "unlisted.txt", Content: "Only those with the link..."}},
+}
+
+var mockBurnSnippet = &models.Snippet{
+	ID:               5,
+	Slug:             "burn",
+	UserID:           1,
+	Title:            "A secret",
+	Created:          time.Now(),
+	Expires:          time.Now(),
+	Visibility:       models.VisibilityUnlisted,
+	ContentType:      models.ContentTypeText,
+	Files:            []*models.File{{Name: "secret.txt", Content: "This message will self-destruct..."}},
+	BurnAfterReading: true,
+}
+
+// MockSnippetPassword is the password of the protected mock snippet.
+const MockSnippetPassword = "open sesame"
+
+var mockProtectedSnippet = &models.Snippet{
+	ID:          6,
+	Slug:        "protected",
+	UserID:      1,
+	Title:       "A protected haiku",
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Visibility:  models.VisibilityPublic,
+	ContentType: models.ContentTypeText,
+	Files:       []*models.File{{Name: "door.txt", Content: "Behind a locked door..."}},
+	Protected:   true,
+}
+
+// MockCiphertext is the content of the encrypted mock snippet.
+const MockCiphertext = "q83vASNFZ4mrze8BI0VniavN7wEjRWeJq83vASNFZ4k="
+
+var mockEncryptedSnippet = &models.Snippet{
+	ID:          7,
+	Slug:        "encrypted",
+	UserID:      1,
+	Title:       "An encrypted haiku",
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Visibility:  models.VisibilityUnlisted,
+	ContentType: models.ContentTypeEncrypted,
+	Files:       []*models.File{{Name: "haiku.txt", Content: MockCiphertext}},
+}
+
+var mockFork = &models.Snippet{
+	ID:          8,
+	Slug:        "fork",
+	UserID:      2,
+	Title:       "A new silent pond",
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Visibility:  models.VisibilityPublic,
+	ContentType: models.ContentTypeText,
+	Files:       []*models.File{{Name: "pond.txt", Content: "A new silent pond..."}},
+	ForkedFrom:  1,
+	Stars:       2,
+}
+
+// mockTeamSnippet is a private snippet of mockTeam, created by Tom.
+var mockTeamSnippet = &models.Snippet{
+	ID:          9,
+	Slug:        "team",
+	UserID:      2,
+	Title:       "A team haiku",
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Visibility:  models.VisibilityPrivate,
+	ContentType: models.ContentTypeText,
+	Files:       []*models.File{{Name: "team.go", Language: "go", Content: "Written together..."}},
+	TeamID:      1,
+}
+
+// mockProtectedTeamSnippet was created by Tom for mockTeam, its password is
+// MockSnippetPassword.
+var mockProtectedTeamSnippet = &models.Snippet{
+	ID:          10,
+	Slug:        "team-protected",
+	UserID:      2,
+	Title:       "A protected team haiku",
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Visibility:  models.VisibilityPrivate,
+	ContentType: models.ContentTypeText,
+	Files:       []*models.File{{Name: "vault.txt", Content: "Kept among ourselves..."}},
+	Protected:   true,
+	TeamID:      1,
+}
+
+// mockLegacySnippet was created before slugs existed and hasn't been given
+// one yet.
+var mockLegacySnippet = &models.Snippet{
+	ID:          11,
+	UserID:      1,
+	Title:       "An older pond",
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Visibility:  models.VisibilityPublic,
+	ContentType: models.ContentTypeText,
+	Files:       []*models.File{{Name: "pond.txt", Content: "Before there were slugs..."}},
+}
+
+// ownedBy reports whether the viewer created the snippet or is a member of
+// the team owning it.
+func ownedBy(s *models.Snippet, viewerID int) bool {
+	if s.UserID == viewerID {
+		return true
+	}
+	_, ok := mockTeamRoles[viewerID]
+	return s.TeamID == 1 && ok
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	s.ID, s.Slug, s.Protected = 2, "new", s.Password != ""
+	return s.ID, nil
+}
+
+func (m *SnippetModel) InsertMany(snippets []*models.Snippet) error {
+	for i, s := range snippets {
+		s.ID, s.Slug, s.Protected = 10+i, fmt.Sprintf("new%d", i+1), s.Password != ""
+	}
+	return nil
+}
+
+func (m *SnippetModel) Update(s *models.Snippet, editorID int) error {
+	switch {
+	case s.ID == 1 && editorID == 1:
+		return nil
+	case s.ID == 9 && (editorID == 1 || editorID == 2):
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
 }
 
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 11:
+		return mockLegacySnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) GetBySlug(slug string, viewerID int) (*models.Snippet, error) {
+	var s *models.Snippet
+	switch slug {
+	case "pond":
+		s = mockSnippet
+	case "private":
+		s = mockPrivateSnippet
+	case "unlisted":
+		s = mockUnlistedSnippet
+	case "burn":
+		s = mockBurnSnippet
+	case "protected":
+		s = mockProtectedSnippet
+	case "encrypted":
+		s = mockEncryptedSnippet
+	case "fork":
+		s = mockFork
+	case "team":
+		s = mockTeamSnippet
+	case "team-protected":
+		s = mockProtectedTeamSnippet
+	default:
+		return nil, models.ErrNoRecord
+	}
+
+	if s.Visibility == models.VisibilityPrivate && !ownedBy(s, viewerID) {
+		return nil, models.ErrNoRecord
+	}
+	// a copy, the snippets being changed by the handlers editing them
+	c := *s
+	return &c, nil
+}
+
+func (m *SnippetModel) GetVisible(id, viewerID int) (*models.Snippet, error) {
+	switch {
+	case id == 1:
+		return mockSnippet, nil
+	case id == 3 && viewerID == 1:
+		return mockPrivateSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Forks(id, viewerID int) ([]*models.Snippet, error) {
+	switch id {
+	case 1:
+		return []*models.Snippet{mockFork}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Burn(slug string, viewerID int) (*models.Snippet, error) {
+	switch slug {
+	case "burn":
+		return mockBurnSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) CheckPassword(id int, password string) error {
+	switch {
+	case id != 6 && id != 10:
+		return models.ErrNoRecord
+	case password != MockSnippetPassword:
+		return models.ErrInvalidCredentials
+	default:
+		return nil
+	}
+}
+
+func (m *SnippetModel) Latest(sort string) ([]*models.Snippet, error) {
+	if sort == models.SortPopular {
+		return []*models.Snippet{mockFork, mockSnippet}, nil
+	}
+	return []*models.Snippet{mockSnippet, mockFork}, nil
+}
+
+func (m *SnippetModel) LatestForUser(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1, 4:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) StarredBy(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockFork}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) ForUser(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet, mockPrivateSnippet, mockUnlistedSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+// EachForUser goes through the snippets of ForUser, along with the encrypted
+// snippet for Alice. For Ada it fails after the first snippet, like a
+// connection to the database lost in the middle of an export.
+func (m *SnippetModel) EachForUser(userID int, fn func(*models.Snippet) error) error {
+	switch userID {
+	case 1:
+		for _, s := range []*models.Snippet{mockSnippet, mockPrivateSnippet, mockUnlistedSnippet, mockEncryptedSnippet} {
+			if err := fn(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 3:
+		if err := fn(mockSnippet); err != nil {
+			return err
+		}
+		return ErrMockLostConnection
+	default:
+		return nil
+	}
+}
+
+func (m *SnippetModel) InCollection(collectionID, viewerID int) ([]*models.Snippet, error) {
+	switch collectionID {
+	case 1:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) ForTeam(teamID int) ([]*models.Snippet, error) {
+	switch teamID {
+	case 1:
+		return []*models.Snippet{mockTeamSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) List(f models.SnippetFilter) ([]*models.Snippet, int, error) {
+	return []*models.Snippet{mockSnippet}, 1, nil
+}
+
+func (m *SnippetModel) Stats() (*models.SnippetStats, error) {
+	return &models.SnippetStats{Total: 1, Live: 1}, nil
+}
+
+func (m *SnippetModel) Usage(userID int) (*models.Usage, error) {
+	switch userID {
+	case 1:
+		return &models.Usage{Snippets: 5, Bytes: 2048, CreatedToday: 2}, nil
+	default:
+		return &models.Usage{}, nil
+	}
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) DeleteExpired() (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) Expire(id int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
 }
@@ -0,0 +1,17 @@
+package mock
+
+// StarModel has alice (user 1) starring the fork (snippet 8), starring and
+// unstarring always succeed.
+type StarModel struct{}
+
+func (m *StarModel) Star(userID, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Unstar(userID, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Starred(userID, snippetID int) (bool, error) {
+	return userID == 1 && snippetID == 8, nil
+}
@@ -0,0 +1,127 @@
+package mock
+
+import (
+	"dsolerh/snippetbox/pkg/models"
+	"time"
+)
+
+var mockTeam = &models.Team{
+	ID:      1,
+	Slug:    "devs",
+	Name:    "Developers",
+	Created: time.Now(),
+}
+
+// mockTeamRoles are the roles of the members of mockTeam: Alice owns it and
+// Tom is a member.
+var mockTeamRoles = map[int]string{
+	1: models.TeamRoleOwner,
+	2: models.TeamRoleMember,
+}
+
+// MockInvitationToken is the token of mockInvitation, which Invite also
+// returns.
+const MockInvitationToken = "invitation-token"
+
+var mockInvitation = &models.Invitation{
+	ID:        1,
+	TeamID:    1,
+	TeamName:  "Developers",
+	Email:     "ada@example.com",
+	InvitedBy: 1,
+	Created:   time.Now(),
+}
+
+type TeamModel struct{}
+
+func (m *TeamModel) Insert(t *models.Team, ownerID int) (int, error) {
+	t.ID, t.Slug = 2, "new"
+	return t.ID, nil
+}
+
+func (m *TeamModel) GetBySlug(slug string) (*models.Team, error) {
+	switch slug {
+	case "devs":
+		return mockTeam, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *TeamModel) ForUser(userID int) ([]*models.Team, error) {
+	if _, ok := mockTeamRoles[userID]; ok {
+		return []*models.Team{mockTeam}, nil
+	}
+	return []*models.Team{}, nil
+}
+
+func (m *TeamModel) Role(teamID, userID int) (string, error) {
+	role, ok := mockTeamRoles[userID]
+	if teamID != 1 || !ok {
+		return "", models.ErrNoRecord
+	}
+	return role, nil
+}
+
+func (m *TeamModel) Members(teamID int) ([]*models.TeamMember, error) {
+	switch teamID {
+	case 1:
+		return []*models.TeamMember{
+			{TeamID: 1, UserID: 1, Name: "Alice", Email: "alice@example.com", Role: models.TeamRoleOwner, Joined: time.Now()},
+			{TeamID: 1, UserID: 2, Name: "Tom", Email: "tom@example.com", Role: models.TeamRoleMember, Joined: time.Now()},
+		}, nil
+	default:
+		return []*models.TeamMember{}, nil
+	}
+}
+
+func (m *TeamModel) SetRole(teamID, userID int, role string) error {
+	switch {
+	case teamID == 1 && userID == 1 && role != models.TeamRoleOwner:
+		return models.ErrLastOwner
+	default:
+		return nil
+	}
+}
+
+func (m *TeamModel) RemoveMember(teamID, userID int) error {
+	switch {
+	case teamID == 1 && userID == 1:
+		return models.ErrLastOwner
+	case teamID == 1 && userID == 2:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *TeamModel) Invite(teamID int, email string, invitedBy int) (string, error) {
+	switch email {
+	case "alice@example.com", "tom@example.com":
+		return "", models.ErrAlreadyMember
+	default:
+		return MockInvitationToken, nil
+	}
+}
+
+func (m *TeamModel) Invitation(token string) (*models.Invitation, error) {
+	switch token {
+	case MockInvitationToken:
+		return mockInvitation, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *TeamModel) Accept(token string, userID int) error {
+	switch token {
+	case MockInvitationToken:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *TeamModel) Decline(token string) error {
+	return m.Accept(token, 0)
+}
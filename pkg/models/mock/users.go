@@ -2,6 +2,7 @@ package mock
 
 import (
 	"dsolerh/snippetbox/pkg/models"
+	"sync"
 	"time"
 )
 
@@ -10,9 +11,49 @@ var mockUser = &models.User{
 	Name:    "Alice",
 	Email:   "alice@example.com",
 	Created: time.Now(),
+	Role:    models.RoleUser,
 }
 
-type UserModel struct{}
+// MockTOTPSecret is the TOTP secret of mockTOTPUser, which has two-factor
+// authentication enabled. MockRecoveryCode is its only recovery code.
+const (
+	MockTOTPSecret   = "JBSWY3DPEHPK3PXP"
+	MockRecoveryCode = "abcde-fghij"
+)
+
+var mockAdmin = &models.User{
+	ID:      3,
+	Name:    "Ada",
+	Email:   "ada@example.com",
+	Created: time.Now(),
+	Role:    models.RoleAdmin,
+}
+
+// mockSuspendedUser can't log in, Authenticate returns ErrSuspended for
+// suspended@example.com.
+var mockSuspendedUser = &models.User{
+	ID:        4,
+	Name:      "Sam",
+	Email:     "suspended@example.com",
+	Created:   time.Now(),
+	Role:      models.RoleUser,
+	Suspended: true,
+}
+
+var mockTOTPUser = &models.User{
+	ID:          2,
+	Name:        "Tom",
+	Email:       "tom@example.com",
+	Created:     time.Now(),
+	TOTPEnabled: true,
+	Role:        models.RoleUser,
+}
+
+type UserModel struct {
+	mu sync.Mutex
+	// the last TOTP step used by every user
+	totpSteps map[int]int64
+}
 
 func (m *UserModel) Insert(name, email, password string) error {
 	switch email {
@@ -27,6 +68,12 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	switch email {
 	case "alice@example.com":
 		return 1, nil
+	case "tom@example.com":
+		return 2, nil
+	case "ada@example.com":
+		return 3, nil
+	case "suspended@example.com":
+		return 0, models.ErrSuspended
 	default:
 		return 0, models.ErrInvalidCredentials
 	}
@@ -36,7 +83,106 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return mockUser, nil
+	case 2:
+		return mockTOTPUser, nil
+	case 3:
+		return mockAdmin, nil
+	case 4:
+		return mockSuspendedUser, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
+
+func (m *UserModel) Delete(id int) error {
+	switch id {
+	case 1:
+		// Alice is the only owner of mockTeam, which Tom is a member of.
+		return models.ErrLastOwner
+	case 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	switch email {
+	case "alice@example.com":
+		return mockUser, nil
+	case "tom@example.com":
+		return mockTOTPUser, nil
+	case "ada@example.com":
+		return mockAdmin, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) SetRole(id int, role string) error {
+	switch id {
+	case 1, 2, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) SetSuspended(id int, suspended bool) error {
+	switch id {
+	case 1, 2, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) List(f models.UserFilter) ([]*models.User, int, error) {
+	return []*models.User{mockUser, mockTOTPUser, mockAdmin}, 3, nil
+}
+
+func (m *UserModel) Stats() (*models.UserStats, error) {
+	return &models.UserStats{Total: 3, Admins: 1}, nil
+}
+
+func (m *UserModel) SetTOTPSecret(id int, secret string) error {
+	return nil
+}
+
+func (m *UserModel) TOTPSecret(id int) (string, error) {
+	switch id {
+	case 1, 2:
+		return MockTOTPSecret, nil
+	default:
+		return "", models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) EnableTOTP(id int, recoveryCodes []string) error {
+	return nil
+}
+
+func (m *UserModel) DisableTOTP(id int) error {
+	return nil
+}
+
+func (m *UserModel) UseTOTPStep(id int, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.totpSteps == nil {
+		m.totpSteps = map[int]int64{}
+	}
+	if step <= m.totpSteps[id] {
+		return models.ErrInvalidCredentials
+	}
+	m.totpSteps[id] = step
+	return nil
+}
+
+func (m *UserModel) UseRecoveryCode(id int, code string) error {
+	if id == 2 && code == MockRecoveryCode {
+		return nil
+	}
+	return models.ErrInvalidCredentials
+}
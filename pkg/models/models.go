@@ -9,14 +9,90 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrSuspended          = errors.New("models: user is suspended")
+	ErrDuplicateSlug      = errors.New("models: duplicate slug")
+	ErrAlreadyMember      = errors.New("models: already a member of the team")
+	ErrLastOwner          = errors.New("models: the team needs an owner")
+)
+
+// The roles a user can have. Every user starts with RoleUser.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// The visibility levels of a snippet. Public snippets are listed on the home
+// page, unlisted ones can only be reached through their unguessable slug and
+// private ones can only be seen by their owner.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// The content types of a snippet. The content of encrypted snippets is
+// base64-encoded ciphertext produced in the browser; the key never reaches
+// the server.
+const (
+	ContentTypeText      = "text"
+	ContentTypeEncrypted = "encrypted"
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID          int
+	Slug        string
+	UserID      int
+	Title       string
+	Created     time.Time
+	Expires     time.Time
+	Visibility  string
+	ContentType string
+	// Files are the files of the snippet, in the order they are shown.
+	// Every snippet has at least one.
+	Files []*File
+	// BurnAfterReading snippets are deleted the first time they are read.
+	BurnAfterReading bool
+	// Protected snippets need a password to be read. Password is only used
+	// when inserting the snippet, only a hash of it is ever stored.
+	Protected bool
+	Password  string
+	// KeyID is the id of the key the content of the files is encrypted with
+	// when it's stored, or empty when it's stored as plaintext.
+	KeyID string
+	// ForkedFrom is the id of the snippet this one is a fork of, or 0.
+	ForkedFrom int
+	// Stars is the number of users who starred the snippet.
+	Stars int
+	// TeamID is the id of the team owning the snippet, or 0 when it belongs
+	// to its creator only. Every member of the team can edit it.
+	TeamID int
+	// Attachments are only used when inserting the snippet, they are read
+	// with IAttachmentModel.
+	Attachments []*Attachment
+}
+
+// File is one of the files of a snippet. The name and the language are only
+// there to help readers, the content of the files of encrypted snippets is
+// ciphertext.
+type File struct {
+	Name     string
+	Language string
+	Content  string
+}
+
+// Attachment is a file attached to a snippet, like a config file or an image.
+// Its content is kept in a blob store under Key, the database only knows
+// about it. ContentType is the type sniffed from its content when it was
+// uploaded.
+type Attachment struct {
+	ID          int
+	SnippetID   int
+	Name        string
+	ContentType string
+	Size        int64
+	Key         string
+	Created     time.Time
 }
 
 type User struct {
@@ -25,4 +101,134 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	TOTPEnabled    bool
+	Role           string
+	Suspended      bool
+}
+
+// UserFilter selects the users returned by IUserModel.List.
+type UserFilter struct {
+	// Search matches part of the name or the email.
+	Search string
+	Role   string
+	Offset int
+	Limit  int
+}
+
+// The orders in which ISnippetModel.Latest can return the snippets.
+const (
+	SortNewest  = "newest"
+	SortPopular = "popular"
+)
+
+// SnippetFilter selects the snippets returned by ISnippetModel.List.
+type SnippetFilter struct {
+	// Search matches part of the title.
+	Search string
+	UserID int
+	Offset int
+	Limit  int
+}
+
+type UserStats struct {
+	Total     int
+	Suspended int
+	Admins    int
+}
+
+type SnippetStats struct {
+	Total int
+	Live  int
+}
+
+// Usage is what a user stores, to enforce their quotas. Only the snippets
+// which haven't expired count, Bytes being the size of the content of their
+// files and of their attachments. CreatedToday counts every snippet created
+// in the last 24 hours.
+type Usage struct {
+	Snippets     int
+	Bytes        int64
+	CreatedToday int
+}
+
+// Session is a server-side login session. The token identifying it is only
+// ever known by the client, we keep a hash of it.
+type Session struct {
+	ID        int
+	UserID    int
+	IP        string
+	UserAgent string
+	Created   time.Time
+	LastSeen  time.Time
+	Expires   time.Time
+}
+
+// Comment is a comment on a snippet. Replies have the id of the comment they
+// answer as their ParentID, and comments about a specific line of the snippet
+// have its number as their Line and the position of its file, from 0, as
+// their File. The comments of the users who deleted their account have 0 as
+// their UserID and no UserName.
+type Comment struct {
+	ID        int
+	SnippetID int
+	UserID    int
+	UserName  string
+	ParentID  int
+	File      int
+	Line      int
+	Content   string
+	Created   time.Time
+	// Updated is the zero time until the comment is edited.
+	Updated time.Time
+	// Deleted comments are kept, without their content, so the replies to
+	// them still make sense.
+	Deleted bool
+}
+
+// Collection is a named list of snippets owned by a user, in the order the
+// user chose. Public collections can be seen by anyone with their link.
+type Collection struct {
+	ID      int
+	Slug    string
+	UserID  int
+	Name    string
+	Public  bool
+	Created time.Time
+}
+
+// The roles of the members of a team. Owners manage the team and its members,
+// every member can create and edit the snippets of the team.
+const (
+	TeamRoleOwner  = "owner"
+	TeamRoleMember = "member"
+)
+
+// Team is a group of users sharing the snippets it owns.
+type Team struct {
+	ID      int
+	Slug    string
+	Name    string
+	Created time.Time
+}
+
+// TeamMember is a user belonging to a team, with their role in it.
+type TeamMember struct {
+	TeamID int
+	UserID int
+	Name   string
+	Email  string
+	Role   string
+	Joined time.Time
+}
+
+// Invitation asks whoever has its token to join the team. The token is only
+// given to the member who invites, to send it to the email, whoever has an
+// account with the email can't join without it.
+type Invitation struct {
+	ID        int
+	TeamID    int
+	TeamName  string
+	Email     string
+	InvitedBy int
+	Created   time.Time
 }
@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// AttachmentModel reads the attachments of the snippets, which are inserted
+// along with them by SnippetModel.Insert. Deleting a snippet leaves its
+// attachments behind as orphans, so their blobs can be deleted before them.
+type AttachmentModel struct {
+	DB *sql.DB
+}
+
+const attachmentColumns = `id, snippet_id, name, content_type, size, blob_key, created`
+
+func scanAttachment(row scanner) (*models.Attachment, error) {
+	a := &models.Attachment{}
+	err := row.Scan(&a.ID, &a.SnippetID, &a.Name, &a.ContentType, &a.Size, &a.Key, &a.Created)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// insertAttachments stores the attachments of a new snippet.
+func insertAttachments(tx *sql.Tx, snippetID int, attachments []*models.Attachment) error {
+	stmt := `INSERT INTO attachments (snippet_id, name, content_type, size, blob_key, created)
+	VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	for _, a := range attachments {
+		result, err := tx.Exec(stmt, snippetID, a.Name, a.ContentType, a.Size, a.Key)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		a.ID, a.SnippetID = int(id), snippetID
+	}
+	return nil
+}
+
+func (m *AttachmentModel) Get(id int) (*models.Attachment, error) {
+	stmt := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = ?`
+	a, err := scanAttachment(m.DB.QueryRow(stmt, id))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ForSnippet returns the attachments of the snippet in the order they were
+// uploaded.
+func (m *AttachmentModel) ForSnippet(snippetID int) ([]*models.Attachment, error) {
+	stmt := `SELECT ` + attachmentColumns + ` FROM attachments WHERE snippet_id = ? ORDER BY id`
+	return m.query(stmt, snippetID)
+}
+
+// Orphans returns the attachments whose snippet has been deleted.
+func (m *AttachmentModel) Orphans() ([]*models.Attachment, error) {
+	stmt := `SELECT ` + attachmentColumns + ` FROM attachments
+	WHERE NOT EXISTS (SELECT 1 FROM snippets WHERE snippets.id = attachments.snippet_id) ORDER BY id`
+	return m.query(stmt)
+}
+
+func (m *AttachmentModel) query(stmt string, args ...interface{}) ([]*models.Attachment, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// Delete forgets about an attachment, once its blob has been deleted.
+func (m *AttachmentModel) Delete(id int) error {
+	result, err := m.DB.Exec(`DELETE FROM attachments WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
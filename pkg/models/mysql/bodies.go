@@ -0,0 +1,116 @@
+package mysql
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// The content of the files of the snippets is stored once per distinct
+// content in snippet_bodies, keyed by its SHA-256 hash, and the files only
+// refer to it by its hash. Every body counts the files referring to it and is
+// deleted along with the last of them. Content encrypted at rest never
+// repeats, so it isn't deduplicated.
+//
+// Big bodies are stored gzipped, the encoding column telling how each body is
+// stored. The hash and the size are always the ones of the content as it is.
+
+const (
+	// content shorter than this is stored as it is, compressing it wouldn't
+	// save much
+	compressThreshold = 1024
+	// the encodings of the bodies
+	encodingNone = ""
+	encodingGzip = "gzip"
+)
+
+// bodyHash returns the key of the content in snippet_bodies.
+func bodyHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// acquireBody stores the content, or adds a reference to it when it's already
+// stored, and returns its hash.
+func acquireBody(tx *sql.Tx, content string) (string, error) {
+	data, encoding, err := encodeBody(content)
+	if err != nil {
+		return "", err
+	}
+
+	hash := bodyHash(content)
+	stmt := `INSERT INTO snippet_bodies (hash, content, encoding, size, refs) VALUES (?, ?, ?, ?, 1)
+	ON DUPLICATE KEY UPDATE refs = refs + 1`
+	if _, err = tx.Exec(stmt, hash, data, encoding, len(content)); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// encodeBody returns the content as it's stored and its encoding. Content
+// above the threshold is gzipped, unless that doesn't make it any smaller.
+func encodeBody(content string) ([]byte, string, error) {
+	if len(content) < compressThreshold {
+		return []byte(content), encodingNone, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, content); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+
+	if buf.Len() >= len(content) {
+		return []byte(content), encodingNone, nil
+	}
+	return buf.Bytes(), encodingGzip, nil
+}
+
+// decodeBody returns the content of a body stored with the encoding.
+func decodeBody(data []byte, encoding string) (string, error) {
+	switch encoding {
+	case encodingNone:
+		return string(data), nil
+	case encodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		defer zr.Close()
+
+		content, err := ioutil.ReadAll(zr)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	default:
+		return "", fmt.Errorf("mysql: unknown body encoding %q", encoding)
+	}
+}
+
+// releaseBodies drops the references of the files matching the condition to
+// their bodies and deletes the bodies which are no longer referenced. The
+// condition is about the columns of snippet_files, and it must be called
+// before the files are deleted or changed.
+func releaseBodies(tx *sql.Tx, files string, args ...interface{}) error {
+	stmt := `UPDATE snippet_bodies JOIN (
+		SELECT body_hash, COUNT(*) AS n FROM snippet_files WHERE ` + files + ` GROUP BY body_hash
+	) released ON released.body_hash = snippet_bodies.hash
+	SET snippet_bodies.refs = snippet_bodies.refs - released.n`
+	if _, err := tx.Exec(stmt, args...); err != nil {
+		return err
+	}
+
+	stmt = `DELETE snippet_bodies FROM snippet_bodies JOIN snippet_files ON snippet_files.body_hash = snippet_bodies.hash
+	WHERE snippet_bodies.refs <= 0 AND ` + files
+	_, err := tx.Exec(stmt, args...)
+	return err
+}
@@ -0,0 +1,246 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// CollectionModel stores the collections and which snippets they contain.
+// Every change takes the id of the user making it and only applies to their
+// own collections, anything else gets the ErrNoRecord error.
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+// the columns read into a models.Collection, in the order expected by
+// scanCollection.
+const collectionColumns = `id, slug, user_id, name, public, created`
+
+func scanCollection(row scanner) (*models.Collection, error) {
+	c := &models.Collection{}
+	err := row.Scan(&c.ID, &c.Slug, &c.UserID, &c.Name, &c.Public, &c.Created)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Insert adds a new collection and fills in its ID and its random slug, the
+// same way as for snippets.
+func (m *CollectionModel) Insert(c *models.Collection) (int, error) {
+	stmt := `INSERT INTO collections (slug, user_id, name, public, created)
+	VALUES (?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	for i := 0; i < slugAttempts; i++ {
+		slug, err := randomSlug(slugLength)
+		if err != nil {
+			return 0, err
+		}
+
+		result, err := m.DB.Exec(stmt, slug, c.UserID, c.Name, c.Public)
+		if isDuplicate(err, "collections_uc_slug") {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+
+		c.ID, c.Slug = int(id), slug
+		return c.ID, nil
+	}
+	return 0, models.ErrDuplicateSlug
+}
+
+// GetBySlug returns a collection if the viewer may see it: public
+// collections can be seen by anyone, the other ones only by their owner.
+func (m *CollectionModel) GetBySlug(slug string, viewerID int) (*models.Collection, error) {
+	stmt := `SELECT ` + collectionColumns + ` FROM collections
+	WHERE slug = ? AND (public = TRUE OR user_id = ?)`
+
+	c, err := scanCollection(m.DB.QueryRow(stmt, slug, viewerID))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (m *CollectionModel) ForUser(userID int) ([]*models.Collection, error) {
+	stmt := `SELECT ` + collectionColumns + ` FROM collections WHERE user_id = ? ORDER BY name, id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*models.Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+func (m *CollectionModel) Rename(id, userID int, name string) error {
+	result, err := m.DB.Exec(`UPDATE collections SET name = ? WHERE id = ? AND user_id = ?`, name, id, userID)
+	if err != nil {
+		return err
+	}
+	return m.checkUpdated(result, id, userID)
+}
+
+func (m *CollectionModel) SetPublic(id, userID int, public bool) error {
+	result, err := m.DB.Exec(`UPDATE collections SET public = ? WHERE id = ? AND user_id = ?`, public, id, userID)
+	if err != nil {
+		return err
+	}
+	return m.checkUpdated(result, id, userID)
+}
+
+// checkUpdated returns the ErrNoRecord error if an UPDATE of the collection
+// didn't find it. MySQL doesn't count the rows which already had the new
+// values as affected, so when nothing changed we check whether the user owns
+// the collection at all.
+func (m *CollectionModel) checkUpdated(result sql.Result, id, userID int) error {
+	if err := expectOneRow(result); err != models.ErrNoRecord {
+		return err
+	}
+	return m.checkOwner(m.DB, id, userID)
+}
+
+// checkOwner returns the ErrNoRecord error unless the collection belongs to
+// the user.
+func (m *CollectionModel) checkOwner(q querier, id, userID int) error {
+	var owned bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM collections WHERE id = ? AND user_id = ?)`
+	if err := q.QueryRow(stmt, id, userID).Scan(&owned); err != nil {
+		return err
+	}
+	if !owned {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Delete removes the collection. The snippets it contained aren't touched.
+func (m *CollectionModel) Delete(id, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM collections WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = expectOneRow(result); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM collection_snippets WHERE collection_id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddSnippet puts the snippet at the end of the collection. Adding a snippet
+// which is already in the collection does nothing.
+func (m *CollectionModel) AddSnippet(id, userID, snippetID int) error {
+	if err := m.checkOwner(m.DB, id, userID); err != nil {
+		return err
+	}
+
+	stmt := `INSERT IGNORE INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?`
+	_, err := m.DB.Exec(stmt, id, snippetID, id)
+	return err
+}
+
+func (m *CollectionModel) RemoveSnippet(id, userID, snippetID int) error {
+	if err := m.checkOwner(m.DB, id, userID); err != nil {
+		return err
+	}
+
+	result, err := m.DB.Exec(`DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`, id, snippetID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// MoveSnippet swaps the snippet with the one before it in the collection when
+// delta is negative, or with the one after it when it's positive. Moving the
+// first snippet up or the last one down does nothing.
+func (m *CollectionModel) MoveSnippet(id, userID, snippetID, delta int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = m.checkOwner(tx, id, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var position int
+	stmt := `SELECT position FROM collection_snippets WHERE collection_id = ? AND snippet_id = ? FOR UPDATE`
+	err = tx.QueryRow(stmt, id, snippetID).Scan(&position)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.ErrNoRecord
+	} else if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt = `SELECT snippet_id, position FROM collection_snippets
+	WHERE collection_id = ? AND position > ? ORDER BY position LIMIT 1 FOR UPDATE`
+	if delta < 0 {
+		stmt = `SELECT snippet_id, position FROM collection_snippets
+		WHERE collection_id = ? AND position < ? ORDER BY position DESC LIMIT 1 FOR UPDATE`
+	}
+
+	var otherID, otherPosition int
+	err = tx.QueryRow(stmt, id, position).Scan(&otherID, &otherPosition)
+	if err == sql.ErrNoRows {
+		// already at the start or the end
+		tx.Rollback()
+		return nil
+	} else if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt = `UPDATE collection_snippets SET position = ? WHERE collection_id = ? AND snippet_id = ?`
+	for _, args := range [][]interface{}{
+		{otherPosition, id, snippetID},
+		{position, id, otherID},
+	} {
+		if _, err = tx.Exec(stmt, args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,130 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// the columns read into a models.Comment, in the order expected by
+// scanComment. The name of the author comes from the users table, aliased u,
+// and is empty for the comments of the deleted users.
+const commentColumns = `c.id, c.snippet_id, c.user_id, IFNULL(u.name, ''), IFNULL(c.parent_id, 0), c.file, c.line, c.content, c.created, c.updated, c.deleted`
+
+func scanComment(row scanner) (*models.Comment, error) {
+	c := &models.Comment{}
+	var updated sql.NullTime
+	err := row.Scan(&c.ID, &c.SnippetID, &c.UserID, &c.UserName, &c.ParentID, &c.File, &c.Line, &c.Content, &c.Created, &updated, &c.Deleted)
+	if err != nil {
+		return nil, err
+	}
+	c.Updated = updated.Time
+	return c, nil
+}
+
+// Insert adds a new comment and fills in its ID.
+func (m *CommentModel) Insert(c *models.Comment) (int, error) {
+	stmt := `INSERT INTO comments (snippet_id, user_id, parent_id, file, line, content, created)
+	VALUES (?, ?, NULLIF(?, 0), ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, c.SnippetID, c.UserID, c.ParentID, c.File, c.Line, c.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	c.ID = int(id)
+	return c.ID, nil
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM comments c LEFT JOIN users u ON u.id = c.user_id
+	WHERE c.id = ?`
+
+	c, err := scanComment(m.DB.QueryRow(stmt, id))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ForSnippet returns all the comments on a snippet, oldest first, so replies
+// always come after the comment they answer.
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM comments c LEFT JOIN users u ON u.id = c.user_id
+	WHERE c.snippet_id = ? ORDER BY c.created, c.id`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	return scanComments(rows)
+}
+
+// ForUser returns every comment written by the user, for the export of their
+// data.
+func (m *CommentModel) ForUser(userID int) ([]*models.Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM comments c LEFT JOIN users u ON u.id = c.user_id
+	WHERE c.user_id = ? AND c.deleted = FALSE ORDER BY c.created, c.id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanComments(rows)
+}
+
+// Update replaces the content of a comment. Only its author can edit it, for
+// anyone else, or when the comment was deleted, we return the ErrNoRecord
+// error.
+func (m *CommentModel) Update(id, userID int, content string) error {
+	stmt := `UPDATE comments SET content = ?, updated = UTC_TIMESTAMP()
+	WHERE id = ? AND user_id = ? AND deleted = FALSE`
+
+	result, err := m.DB.Exec(stmt, content, id, userID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// Delete removes the content of a comment, with the same rules as Update. The
+// comment itself stays so that the thread it's part of isn't broken.
+func (m *CommentModel) Delete(id, userID int) error {
+	stmt := `UPDATE comments SET content = '', deleted = TRUE
+	WHERE id = ? AND user_id = ? AND deleted = FALSE`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// scanComments reads all the rows of a comments query and closes them.
+func scanComments(rows *sql.Rows) ([]*models.Comment, error) {
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
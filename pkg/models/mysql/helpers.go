@@ -0,0 +1,77 @@
+package mysql
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"strings"
+
+	"dsolerh/snippetbox/pkg/models"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// likePattern turns a search term into a LIKE pattern matching any value
+// which contains it.
+func likePattern(search string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(search) + "%"
+}
+
+// expectOneRow returns the ErrNoRecord error if the statement didn't affect
+// any row.
+func expectOneRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// randomToken returns n random bytes encoded so they can be used in URLs.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+const slugAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randomSlug returns a random string of n letters and digits. Each character
+// carries almost 6 bits of entropy, so 10 of them are plenty to make a slug
+// impossible to guess.
+func randomSlug(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		// 62 doesn't divide 256 so this is slightly biased, which doesn't
+		// matter for slugs
+		b[i] = slugAlphabet[int(b[i])%len(slugAlphabet)]
+	}
+	return string(b), nil
+}
+
+// isDuplicate reports whether err is a MySQL duplicate entry error on the
+// given unique key.
+func isDuplicate(err error, key string) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, key)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
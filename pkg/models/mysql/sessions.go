@@ -0,0 +1,127 @@
+package mysql
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// Create starts a new session for the user and returns the random token which
+// identifies it. Only a hash of the token is stored, so a leaked database
+// can't be used to hijack sessions.
+func (m *SessionModel) Create(userID int, ip, userAgent string, lifetime time.Duration) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	stmt := `INSERT INTO sessions (token_hash, user_id, ip, user_agent, created, last_seen, expires)
+	VALUES (?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err = m.DB.Exec(stmt, hashToken(token), userID, ip, userAgent, int(lifetime.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Get returns the session identified by the token if it hasn't expired yet.
+func (m *SessionModel) Get(token string) (*models.Session, error) {
+	stmt := `SELECT id, user_id, ip, user_agent, created, last_seen, expires FROM sessions
+	WHERE expires > UTC_TIMESTAMP() AND token_hash = ?`
+
+	s := &models.Session{}
+	err := m.DB.QueryRow(stmt, hashToken(token)).Scan(&s.ID, &s.UserID, &s.IP, &s.UserAgent, &s.Created, &s.LastSeen, &s.Expires)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Touch records that the session has just been used.
+func (m *SessionModel) Touch(id int) error {
+	_, err := m.DB.Exec(`UPDATE sessions SET last_seen = UTC_TIMESTAMP() WHERE id = ?`, id)
+	return err
+}
+
+// ForUser returns the active sessions of the user, most recently used first.
+func (m *SessionModel) ForUser(userID int) ([]*models.Session, error) {
+	stmt := `SELECT id, user_id, ip, user_agent, created, last_seen, expires FROM sessions
+	WHERE expires > UTC_TIMESTAMP() AND user_id = ? ORDER BY last_seen DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		s := &models.Session{}
+		err = rows.Scan(&s.ID, &s.UserID, &s.IP, &s.UserAgent, &s.Created, &s.LastSeen, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Delete ends the session identified by the token.
+func (m *SessionModel) Delete(token string) error {
+	_, err := m.DB.Exec(`DELETE FROM sessions WHERE token_hash = ?`, hashToken(token))
+	return err
+}
+
+// Revoke ends one of the user's sessions. If the session doesn't exist or
+// belongs to somebody else we return the ErrNoRecord error.
+func (m *SessionModel) Revoke(userID, id int) error {
+	result, err := m.DB.Exec(`DELETE FROM sessions WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// RevokeOthers ends all of the user's sessions except the one with keepID.
+func (m *SessionModel) RevokeOthers(userID, keepID int) error {
+	_, err := m.DB.Exec(`DELETE FROM sessions WHERE user_id = ? AND id <> ?`, userID, keepID)
+	return err
+}
+
+// DeleteExpired removes the sessions which have expired and returns how many
+// there were.
+func (m *SessionModel) DeleteExpired() (int, error) {
+	result, err := m.DB.Exec(`DELETE FROM sessions WHERE expires <= UTC_TIMESTAMP()`)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
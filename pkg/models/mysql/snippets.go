@@ -2,78 +2,773 @@ package mysql
 
 import (
 	"database/sql"
+	"strings"
+	"time"
 
 	"dsolerh/snippetbox/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type SnippetModel struct {
 	DB *sql.DB
 }
 
-// This will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// the columns read into a models.Snippet, in the order expected by scanSnippet.
+// The slug is NULL for the snippets which haven't been backfilled yet. The
+// files are read separately, by loadFiles.
+const snippetColumns = `snippets.id, IFNULL(snippets.slug, ''), snippets.user_id, title, snippets.created, expires, visibility, content_type, burn_after_reading, hashed_password <> '', key_id, IFNULL(forked_from, 0), IFNULL(snippets.team_id, 0), ` + starCount
+
+// the number of stars of the snippet, counted on the stars primary key
+const starCount = `(SELECT COUNT(*) FROM stars WHERE stars.snippet_id = snippets.id)`
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+func scanSnippet(row scanner) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var expires sql.NullTime
+	err := row.Scan(&s.ID, &s.Slug, &s.UserID, &s.Title, &s.Created, &expires, &s.Visibility, &s.ContentType, &s.BurnAfterReading, &s.Protected, &s.KeyID, &s.ForkedFrom, &s.TeamID, &s.Stars)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	// snippets which never expire have no expiry date
+	s.Expires = expires.Time
+	return s, nil
+}
+
+// getSnippet runs a query returning at most one snippet and reads its files.
+// If there is no such snippet we return the ErrNoRecord error.
+func getSnippet(q querier, stmt string, args ...interface{}) (*models.Snippet, error) {
+	s, err := scanSnippet(q.QueryRow(stmt, args...))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = loadFiles(q, []*models.Snippet{s}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// querySnippets runs a query returning snippets and reads their files.
+func querySnippets(q querier, stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	if err = loadFiles(q, snippets); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// loadFiles reads the files of all the snippets with a single query, their
+// content decompressed.
+func loadFiles(q querier, snippets []*models.Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.Snippet, len(snippets))
+	ids := make([]interface{}, 0, len(snippets))
+	for _, s := range snippets {
+		byID[s.ID] = s
+		ids = append(ids, s.ID)
+	}
+
+	stmt := `SELECT f.snippet_id, f.name, f.language, b.content, b.encoding FROM snippet_files f
+	JOIN snippet_bodies b ON b.hash = f.body_hash
+	WHERE f.snippet_id IN (?` + strings.Repeat(", ?", len(ids)-1) + `) ORDER BY f.snippet_id, f.position`
+
+	rows, err := q.Query(stmt, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var data []byte
+		var encoding string
+		f := &models.File{}
+		if err = rows.Scan(&id, &f.Name, &f.Language, &data, &encoding); err != nil {
+			return err
+		}
+		if f.Content, err = decodeBody(data, encoding); err != nil {
+			return err
+		}
+		byID[id].Files = append(byID[id].Files, f)
+	}
+	return rows.Err()
+}
+
+// insertFiles stores the files of a snippet in the order they are given, and
+// their content in snippet_bodies.
+func insertFiles(tx *sql.Tx, id int, files []*models.File) error {
+	stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, body_hash) VALUES (?, ?, ?, ?, ?)`
+	for i, f := range files {
+		hash, err := acquireBody(tx, f.Content)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(stmt, id, i, f.Name, f.Language, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the condition matching the snippets which haven't expired yet
+const notExpired = `(expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+// the condition matching the snippets which can be listed or found by their
+// id, which burn after reading snippets can't even when public: they are only
+// ever read through their slug, by Burn
+const notBurned = `burn_after_reading = FALSE`
+
+// the condition matching the snippets the viewer owns, either because they
+// created it or because it belongs to one of their teams. It takes the id of
+// the viewer twice.
+const ownedByViewer = `(snippets.user_id = ? OR snippets.team_id IN (SELECT team_id FROM team_members WHERE team_members.user_id = ?))`
+
+// editableByViewer matches the snippets the viewer may edit: team snippets
+// can be edited by every member of the team, whoever created them, and the
+// other ones only by their owner.
+const editableByViewer = `((snippets.team_id IS NULL AND snippets.user_id = ?) OR snippets.team_id IN (SELECT team_id FROM team_members WHERE team_members.user_id = ?))`
+
+const (
+	// length of the random slugs
+	slugLength = 10
+	// how many slugs are tried before giving up on inserting a snippet
+	slugAttempts = 5
+)
+
+// This will insert a new snippet, its files and its attachments into the
+// database and fill in its ID and its random slug. The snippet never expires
+// when its Expires is the zero time. The slug is what makes the links to
+// unlisted snippets impossible to guess. In the unlikely case it's already
+// taken a new one is generated. If the snippet has a password, it's stored
+// bcrypt-hashed like the passwords of the users.
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	tx, err := m.DB.Begin()
 	if err != nil {
 		return 0, err
 	}
 
-	return int(id), nil
+	if err = insertSnippet(tx, s); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return s.ID, nil
 }
 
-// This will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+// InsertMany inserts all the snippets like Insert, in a single transaction:
+// either all of them are inserted or none is.
+func (m *SnippetModel) InsertMany(snippets []*models.Snippet) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
 
-	// execute the query
-	row := m.DB.QueryRow(stmt, id)
+	for _, s := range snippets {
+		if err = insertSnippet(tx, s); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
 
-	// create an empty Snippet
-	s := &models.Snippet{}
+func insertSnippet(tx *sql.Tx, s *models.Snippet) error {
+	hashedPassword := []byte{}
+	if s.Password != "" {
+		var err error
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(s.Password), 12)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt := `INSERT INTO snippets (slug, user_id, title, created, expires, visibility, content_type, burn_after_reading, hashed_password, key_id, forked_from, team_id)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), ?, ?, ?, ?, ?, ?, NULLIF(?, 0), NULLIF(?, 0))`
 
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	expires := sql.NullTime{Time: s.Expires, Valid: !s.Expires.IsZero()}
+
+	for i := 0; i < slugAttempts; i++ {
+		slug, err := randomSlug(slugLength)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(stmt, slug, s.UserID, s.Title, expires, s.Visibility, s.ContentType, s.BurnAfterReading, string(hashedPassword), s.KeyID, s.ForkedFrom, s.TeamID)
+		if isDuplicate(err, "snippets_uc_slug") {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if err = insertFiles(tx, int(id), s.Files); err != nil {
+			return err
+		}
+		if err = insertAttachments(tx, int(id), s.Attachments); err != nil {
+			return err
+		}
+
+		s.ID, s.Slug, s.Protected = int(id), slug, len(hashedPassword) > 0
+		return nil
+	}
+	return models.ErrDuplicateSlug
+}
+
+// Update replaces the title and the files of the snippet, if the editor may
+// edit it. Otherwise we return the ErrNoRecord error, like for a snippet which
+// doesn't exist.
+func (m *SnippetModel) Update(s *models.Snippet, editorID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	var id int
+	stmt := `SELECT id FROM snippets WHERE id = ? AND ` + editableByViewer + ` FOR UPDATE`
+	err = tx.QueryRow(stmt, s.ID, editorID, editorID).Scan(&id)
 	if err == sql.ErrNoRows {
-		return nil, models.ErrNoRecord
+		tx.Rollback()
+		return models.ErrNoRecord
 	} else if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE snippets SET title = ?, key_id = ? WHERE id = ?`, s.Title, s.KeyID, s.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = releaseBodies(tx, `snippet_id = ?`, s.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM snippet_files WHERE snippet_id = ?`, s.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = insertFiles(tx, s.ID, s.Files); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// This will return a public snippet based on its id. Only public snippets can
+// be found by their sequential id, everything else needs the slug.
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE ` + notExpired + ` AND ` + notBurned + ` AND visibility = 'public' AND id = ?`
+
+	return getSnippet(m.DB, stmt, id)
+}
+
+// This will return a specific snippet based on its slug, as long as the
+// viewer is allowed to see it: knowing the slug is enough for public and
+// unlisted snippets, private ones can only be seen by their owner, or by the
+// members of the team owning them. Anonymous viewers have the viewerID 0.
+func (m *SnippetModel) GetBySlug(slug string, viewerID int) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE ` + notExpired + ` AND slug = ? AND (visibility <> 'private' OR ` + ownedByViewer + `)`
+
+	return getSnippet(m.DB, stmt, slug, viewerID, viewerID)
+}
+
+// GetVisible returns a snippet by its id if the viewer could find it without
+// being given its slug, that is public snippets and the ones the viewer owns.
+func (m *SnippetModel) GetVisible(id, viewerID int) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE ` + notExpired + ` AND id = ? AND (visibility = 'public' OR ` + ownedByViewer + `)`
+
+	return getSnippet(m.DB, stmt, id, viewerID, viewerID)
+}
+
+// Forks returns the forks of a snippet, newest first, with the same
+// visibility rules as GetVisible: listing an unlisted fork would give its slug
+// away.
+func (m *SnippetModel) Forks(id, viewerID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE ` + notExpired + ` AND forked_from = ? AND (visibility = 'public' OR ` + ownedByViewer + `)
+	ORDER BY created DESC`
+
+	return querySnippets(m.DB, stmt, id, viewerID, viewerID)
+}
+
+// Burn returns a burn after reading snippet and deletes it, with the same
+// visibility rules as GetBySlug. The row is locked while it's read and
+// deleted within the same transaction, so when two requests race for the
+// snippet only one of them gets it and the other one gets ErrNoRecord.
+func (m *SnippetModel) Burn(slug string, viewerID int) (*models.Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE ` + notExpired + ` AND slug = ? AND (visibility <> 'private' OR ` + ownedByViewer + `)
+	AND burn_after_reading = TRUE FOR UPDATE`
+
+	s, err := getSnippet(tx, stmt, slug, viewerID, viewerID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = releaseBodies(tx, `snippet_id = ?`, s.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM comments WHERE snippet_id = ?`,
+		`DELETE FROM stars WHERE snippet_id = ?`,
+		`DELETE FROM collection_snippets WHERE snippet_id = ?`,
+		`DELETE FROM snippet_files WHERE snippet_id = ?`,
+	} {
+		_, err = tx.Exec(stmt, s.ID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE id = ?`, s.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = expectOneRow(result); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 	return s, nil
 }
 
-// This will return a specific snippet based on its id.
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
+// CheckPassword checks the password of a protected snippet. If it doesn't
+// match, or the snippet has no password, we return the ErrInvalidCredentials
+// error.
+func (m *SnippetModel) CheckPassword(id int, password string) error {
+	var hashedPassword []byte
+	err := m.DB.QueryRow(`SELECT hashed_password FROM snippets WHERE id = ?`, id).Scan(&hashedPassword)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+	if len(hashedPassword) == 0 {
+		return models.ErrInvalidCredentials
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return models.ErrInvalidCredentials
+	}
+	return err
+}
+
+// This will return 10 public snippets, either the most recently created ones
+// or, for models.SortPopular, the most starred ones.
+func (m *SnippetModel) Latest(sort string) ([]*models.Snippet, error) {
+	order := `created DESC`
+	if sort == models.SortPopular {
+		order = starCount + ` DESC, created DESC`
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE ` + notExpired + ` AND ` + notBurned + ` AND visibility = 'public' ORDER BY ` + order + ` LIMIT 10`
 
 	// execute the query
-	rows, err := m.DB.Query(stmt)
+	return querySnippets(m.DB, stmt)
+}
+
+// LatestForUser returns the 10 most recent public snippets of the user which
+// haven't expired.
+func (m *SnippetModel) LatestForUser(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = ? AND ` + notExpired + ` AND ` + notBurned + ` AND visibility = 'public' ORDER BY created DESC LIMIT 10`
+
+	return querySnippets(m.DB, stmt, userID)
+}
+
+// This will return every snippet owned by the given user, including the
+// expired ones, so it can be used to export all of the user's data.
+func (m *SnippetModel) ForUser(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = ? ORDER BY created`
+
+	return querySnippets(m.DB, stmt, userID)
+}
+
+// eachBatchSize is how many snippets EachForUser reads at a time. Every
+// snippet can hold a few megabytes of files, the batches are kept small so
+// they don't take more than a few dozen megabytes of memory.
+const eachBatchSize = 10
+
+// EachForUser calls fn with every snippet created by the user, oldest first,
+// and stops at the first error it returns. The snippets are read in batches
+// of eachBatchSize, only a single batch being in memory at a time.
+func (m *SnippetModel) EachForUser(userID int, fn func(*models.Snippet) error) error {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?`
+
+	lastID := 0
+	for {
+		snippets, err := querySnippets(m.DB, stmt, userID, lastID, eachBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, s := range snippets {
+			if err = fn(s); err != nil {
+				return err
+			}
+		}
+		if len(snippets) < eachBatchSize {
+			return nil
+		}
+		lastID = snippets[len(snippets)-1].ID
+	}
+}
+
+// StarredBy returns the snippets starred by the user, most recently starred
+// first. Snippets the user can no longer see are left out.
+func (m *SnippetModel) StarredBy(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM stars JOIN snippets ON snippets.id = stars.snippet_id
+	WHERE stars.user_id = ? AND ` + notExpired + ` AND (visibility <> 'private' OR ` + ownedByViewer + `)
+	ORDER BY stars.created DESC`
+
+	return querySnippets(m.DB, stmt, userID, userID, userID)
+}
+
+// InCollection returns the snippets of a collection, in the order chosen by
+// its owner. Private snippets are only listed to their owner, and unlisted
+// ones only when they belong to the owner of the collection, who can share
+// them that way.
+func (m *SnippetModel) InCollection(collectionID, viewerID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM collection_snippets cs
+	JOIN collections c ON c.id = cs.collection_id
+	JOIN snippets ON snippets.id = cs.snippet_id
+	WHERE cs.collection_id = ? AND ` + notExpired + ` AND (visibility = 'public' OR ` + ownedByViewer + `
+	OR (visibility = 'unlisted' AND snippets.user_id = c.user_id))
+	ORDER BY cs.position, cs.snippet_id`
+
+	return querySnippets(m.DB, stmt, collectionID, viewerID, viewerID)
+}
+
+// ForTeam returns the snippets owned by the team which haven't expired,
+// newest first. Only the members of the team should be shown the list.
+func (m *SnippetModel) ForTeam(teamID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE team_id = ? AND ` + notExpired + ` ORDER BY created DESC`
+
+	return querySnippets(m.DB, stmt, teamID)
+}
+
+// List returns a page of the snippets matching the filter, expired or not,
+// newest first, along with the total number of matching snippets.
+func (m *SnippetModel) List(f models.SnippetFilter) ([]*models.Snippet, int, error) {
+	where := `WHERE (? = '' OR title LIKE ?) AND (? = 0 OR user_id = ?)`
+	args := []interface{}{f.Search, likePattern(f.Search), f.UserID, f.UserID}
+
+	var total int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets ` + where + ` ORDER BY created DESC, id DESC LIMIT ? OFFSET ?`
+	snippets, err := querySnippets(m.DB, stmt, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, total, nil
+}
+
+func (m *SnippetModel) Stats() (*models.SnippetStats, error) {
+	stmt := `SELECT COUNT(*), COALESCE(SUM(` + notExpired + `), 0) FROM snippets`
+
+	s := &models.SnippetStats{}
+	err := m.DB.QueryRow(stmt).Scan(&s.Total, &s.Live)
 	if err != nil {
 		return nil, err
 	}
+	return s, nil
+}
+
+// Usage returns what the user stores, the snippets of their teams they
+// created included.
+func (m *SnippetModel) Usage(userID int) (*models.Usage, error) {
+	stmt := `SELECT
+	(SELECT COUNT(*) FROM snippets WHERE user_id = ? AND ` + notExpired + `),
+	(SELECT COALESCE(SUM(b.size), 0) FROM snippets s
+		JOIN snippet_files f ON f.snippet_id = s.id
+		JOIN snippet_bodies b ON b.hash = f.body_hash
+		WHERE s.user_id = ? AND ` + notExpired + `),
+	(SELECT COALESCE(SUM(a.size), 0) FROM snippets s
+		JOIN attachments a ON a.snippet_id = s.id
+		WHERE s.user_id = ? AND ` + notExpired + `),
+	(SELECT COUNT(*) FROM snippets WHERE user_id = ? AND created > UTC_TIMESTAMP() - INTERVAL 1 DAY)`
+
+	u := &models.Usage{}
+	var content, attachments int64
+	err := m.DB.QueryRow(stmt, userID, userID, userID, userID).Scan(&u.Snippets, &content, &attachments, &u.CreatedToday)
+	if err != nil {
+		return nil, err
+	}
+	u.Bytes = content + attachments
+	return u, nil
+}
+
+// Delete removes the snippet, its files, its comments and its stars, and
+// takes it out of the collections. If it doesn't exist we return
+// the ErrNoRecord error.
+func (m *SnippetModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = releaseBodies(tx, `snippet_id = ?`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM comments WHERE snippet_id = ?`,
+		`DELETE FROM stars WHERE snippet_id = ?`,
+		`DELETE FROM collection_snippets WHERE snippet_id = ?`,
+		`DELETE FROM snippet_files WHERE snippet_id = ?`,
+	} {
+		_, err = tx.Exec(stmt, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = expectOneRow(result); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Expire makes the snippet expire right away. It stays in the database until
+// DeleteExpired runs but it can no longer be seen.
+func (m *SnippetModel) Expire(id int) error {
+	stmt := `UPDATE snippets SET expires = UTC_TIMESTAMP() WHERE id = ? AND ` + notExpired
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// DeleteExpired deletes the snippets which have expired, like Delete does, and
+// returns how many there were. Their attachments are left to be removed with
+// the other orphans.
+func (m *SnippetModel) DeleteExpired() (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	// The same snippets are deleted from every table, even if others expire
+	// in the meantime.
+	var now time.Time
+	if err = tx.QueryRow(`SELECT UTC_TIMESTAMP()`).Scan(&now); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	expired := `snippet_id IN (SELECT id FROM snippets WHERE expires <= ?)`
+	if err = releaseBodies(tx, expired, now); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	for _, table := range []string{"comments", "stars", "collection_snippets", "snippet_files"} {
+		_, err = tx.Exec(`DELETE FROM `+table+` WHERE `+expired, now)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE expires <= ?`, now)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// scanSnippets reads all the rows of a snippets query and closes them.
+func scanSnippets(rows *sql.Rows) ([]*models.Snippet, error) {
 	defer rows.Close()
 
 	snippets := []*models.Snippet{}
 
 	for rows.Next() {
-		s := &models.Snippet{}
-
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Expires, &s.Created)
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
 		snippets = append(snippets, s)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// StaleContent returns up to limit snippets which weren't encrypted with the
+// given key, with only their id, key id and files filled in.
+func (m *SnippetModel) StaleContent(keyID string, limit int) ([]*models.Snippet, error) {
+	rows, err := m.DB.Query(`SELECT id, key_id FROM snippets WHERE key_id <> ? ORDER BY id LIMIT ?`, keyID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		if err = rows.Scan(&s.ID, &s.KeyID); err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+	if err = loadFiles(m.DB, snippets); err != nil {
+		return nil, err
+	}
 	return snippets, nil
 }
+
+// SetContent replaces the stored content of the files of the snippet, given in
+// the same order, and the id of the key they are encrypted with.
+func (m *SnippetModel) SetContent(id int, files []*models.File, keyID string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE snippets SET key_id = ? WHERE id = ?`, keyID, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt := `UPDATE snippet_files SET body_hash = ? WHERE snippet_id = ? AND position = ?`
+	for i, f := range files {
+		if err = releaseBodies(tx, `snippet_id = ? AND position = ?`, id, i); err != nil {
+			tx.Rollback()
+			return err
+		}
+		hash, err := acquireBody(tx, f.Content)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err = tx.Exec(stmt, hash, id, i); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// BackfillSlugs gives a slug to the snippets created before slugs existed and
+// returns how many were updated.
+func (m *SnippetModel) BackfillSlugs() (int, error) {
+	rows, err := m.DB.Query(`SELECT id FROM snippets WHERE slug IS NULL OR slug = ''`)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		err = m.setSlug(id)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (m *SnippetModel) setSlug(id int) error {
+	for i := 0; i < slugAttempts; i++ {
+		slug, err := randomSlug(slugLength)
+		if err != nil {
+			return err
+		}
+
+		_, err = m.DB.Exec(`UPDATE snippets SET slug = ? WHERE id = ?`, slug, id)
+		if isDuplicate(err, "snippets_uc_slug") {
+			continue
+		}
+		return err
+	}
+	return models.ErrDuplicateSlug
+}
@@ -0,0 +1,384 @@
+package mysql
+
+import (
+	"database/sql"
+	"dsolerh/snippetbox/pkg/models"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSnippetModelBurn(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+
+	// Race a few readers for the same snippet, only one of them may get it.
+	const readers = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Burn("burnme", 0)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	burned := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			burned++
+		case models.ErrNoRecord:
+		default:
+			t.Fatal(err)
+		}
+	}
+	if burned != 1 {
+		t.Errorf("want the snippet to be read exactly once; got %d", burned)
+	}
+
+	_, err := m.GetBySlug("burnme", 0)
+	if err != models.ErrNoRecord {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+}
+
+func TestSnippetModelLatestBurn(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+
+	id, err := m.Insert(&models.Snippet{
+		UserID:           1,
+		Title:            "A public secret",
+		Visibility:       models.VisibilityPublic,
+		ContentType:      models.ContentTypeText,
+		BurnAfterReading: true,
+		Files:            []*models.File{{Name: "secret.txt", Content: "Read me once"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Get(id); err != models.ErrNoRecord {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+	latest, err := m.Latest(models.SortNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forUser, err := m.LatestForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range append(latest, forUser...) {
+		if s.ID == id {
+			t.Errorf("want the burn after reading snippet not to be listed")
+		}
+	}
+}
+
+func TestSnippetModelBodies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+
+	// refs returns how many files refer to the content, 0 once it's gone.
+	refs := func(content string) int {
+		var n int
+		err := db.QueryRow(`SELECT refs FROM snippet_bodies WHERE hash = ?`, bodyHash(content)).Scan(&n)
+		if err == sql.ErrNoRows {
+			return 0
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	const log = "panic: runtime error: index out of range"
+	var snippets []*models.Snippet
+	for i := 0; i < 2; i++ {
+		s := &models.Snippet{
+			UserID:      1,
+			Title:       "Crash",
+			Visibility:  models.VisibilityPublic,
+			ContentType: models.ContentTypeText,
+			Files:       []*models.File{{Name: "a.log", Content: log}, {Name: "b.log", Content: log}},
+		}
+		if _, err := m.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+		snippets = append(snippets, s)
+	}
+	if n := refs(log); n != 4 {
+		t.Errorf("want the content stored once for its 4 files; got %d references", n)
+	}
+
+	s, err := m.GetBySlug(snippets[1].Slug, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Files) != 2 || s.Files[1].Content != log {
+		t.Errorf("want the files to be read with their content; got %+v", s.Files)
+	}
+
+	if err = m.Delete(snippets[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := refs(log); n != 2 {
+		t.Errorf("want 2 references left once a snippet is deleted; got %d", n)
+	}
+
+	snippets[1].Files = []*models.File{{Name: "a.log", Content: "fixed"}}
+	if err = m.Update(snippets[1], 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := refs(log); n != 0 {
+		t.Errorf("want the content to be deleted with its last file; got %d references", n)
+	}
+	if n := refs("fixed"); n != 1 {
+		t.Errorf("want the new content to be stored; got %d references", n)
+	}
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+	users := UserModel{DB: db}
+	teams := TeamModel{DB: db}
+
+	for _, name := range []string{"Bob", "Carol"} {
+		if err := users.Insert(name, strings.ToLower(name)+"@example.com", "validPa$$word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	teamID, err := teams.Insert(&models.Team{Name: "Developers"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO team_members (team_id, user_id, role, joined) VALUES (?, 2, ?, UTC_TIMESTAMP())`, teamID, models.TeamRoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	own := &models.Snippet{UserID: 1, Title: "Mine"}
+	team := &models.Snippet{UserID: 1, TeamID: teamID, Title: "Ours"}
+	for _, s := range []*models.Snippet{own, team} {
+		s.Visibility, s.ContentType = models.VisibilityPublic, models.ContentTypeText
+		s.Files = []*models.File{{Name: "a.txt", Content: s.Title}}
+		if _, err = m.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		snippet  *models.Snippet
+		editorID int
+		wantErr  error
+	}{
+		{"Owner", own, 1, nil},
+		{"Someone else", own, 2, models.ErrNoRecord},
+		{"Anonymous", own, 0, models.ErrNoRecord},
+		{"Team member", team, 2, nil},
+		{"Not a team member", team, 3, models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.Update(tt.snippet, tt.editorID); err != tt.wantErr {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSnippetModelDeleteExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+
+	refs := func(content string) int {
+		var n int
+		err := db.QueryRow(`SELECT refs FROM snippet_bodies WHERE hash = ?`, bodyHash(content)).Scan(&n)
+		if err == sql.ErrNoRows {
+			return 0
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// The shared content is stored once, for a snippet which expires and
+	// one which doesn't.
+	const shared, own = "An old silent pond...", "A frog jumps into the pond"
+	var ids []int
+	for _, files := range [][]*models.File{
+		{{Name: "pond.txt", Content: shared}, {Name: "frog.txt", Content: own}},
+		{{Name: "pond.txt", Content: shared}},
+	} {
+		id, err := m.Insert(&models.Snippet{
+			UserID:      1,
+			Title:       "Haiku",
+			Visibility:  models.VisibilityPublic,
+			ContentType: models.ContentTypeText,
+			Files:       files,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := m.Expire(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := m.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 expired snippet deleted; got %d", n)
+	}
+	if n := refs(own); n != 0 {
+		t.Errorf("want the content of the expired snippet to be deleted; got %d references", n)
+	}
+	if n := refs(shared); n != 1 {
+		t.Errorf("want the shared content to be kept; got %d references", n)
+	}
+	if _, err = m.Get(ids[1]); err != nil {
+		t.Errorf("want the other snippet to be kept; got %v", err)
+	}
+}
+
+func TestSnippetModelCompression(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+
+	small := "An old silent pond..."
+	big := strings.Repeat("A frog jumps into the pond,\nsplash! Silence again.\n", 100)
+	s := &models.Snippet{
+		UserID:      1,
+		Title:       "Haiku",
+		Visibility:  models.VisibilityPublic,
+		ContentType: models.ContentTypeText,
+		Files:       []*models.File{{Name: "small.txt", Content: small}, {Name: "big.txt", Content: big}},
+	}
+	if _, err := m.Insert(s); err != nil {
+		t.Fatal(err)
+	}
+
+	for content, want := range map[string]string{small: encodingNone, big: encodingGzip} {
+		var encoding string
+		var size int
+		err := db.QueryRow(`SELECT encoding, LENGTH(content) FROM snippet_bodies WHERE hash = ?`, bodyHash(content)).Scan(&encoding, &size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoding != want {
+			t.Errorf("want content of %d bytes stored with encoding %q; got %q", len(content), want, encoding)
+		}
+		if encoding == encodingGzip && size >= len(content) {
+			t.Errorf("want the compressed content to be smaller; got %d bytes for %d", size, len(content))
+		}
+	}
+
+	got, err := m.GetBySlug(s.Slug, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Files) != 2 || got.Files[0].Content != small || got.Files[1].Content != big {
+		t.Errorf("want the files to be read back as they were written")
+	}
+}
+
+func TestSnippetModelEachForUser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+
+	// Enough snippets for more than one batch.
+	for i := 0; i < eachBatchSize+1; i++ {
+		s := &models.Snippet{
+			UserID:      2,
+			Title:       "Haiku",
+			Visibility:  models.VisibilityPrivate,
+			ContentType: models.ContentTypeText,
+			Files:       []*models.File{{Name: "haiku.txt", Content: "An old silent pond..."}},
+		}
+		if _, err := m.Insert(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lastID, n := 0, 0
+	err := m.EachForUser(2, func(s *models.Snippet) error {
+		if s.ID <= lastID {
+			t.Errorf("want the snippets in order; got %d after %d", s.ID, lastID)
+		}
+		if len(s.Files) != 1 || s.Files[0].Content != "An old silent pond..." {
+			t.Errorf("want the snippets with their files; got %+v", s.Files)
+		}
+		lastID = s.ID
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != eachBatchSize+1 {
+		t.Errorf("want %d snippets; got %d", eachBatchSize+1, n)
+	}
+
+	// The first error stops the iteration.
+	n = 0
+	err = m.EachForUser(2, func(s *models.Snippet) error {
+		n++
+		return sql.ErrConnDone
+	})
+	if err != sql.ErrConnDone || n != 1 {
+		t.Errorf("want to stop at the first error; got %v after %d snippets", err, n)
+	}
+}
@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"database/sql"
+)
+
+// StarModel records which users starred which snippets. A star is a row keyed
+// by the user and the snippet, so starring twice, even concurrently, can never
+// count twice.
+type StarModel struct {
+	DB *sql.DB
+}
+
+// Star stars the snippet for the user. Starring a snippet which is already
+// starred does nothing.
+func (m *StarModel) Star(userID, snippetID int) error {
+	stmt := `INSERT IGNORE INTO stars (user_id, snippet_id, created) VALUES (?, ?, UTC_TIMESTAMP())`
+	_, err := m.DB.Exec(stmt, userID, snippetID)
+	return err
+}
+
+// Unstar removes the star of the user from the snippet, if there's one.
+func (m *StarModel) Unstar(userID, snippetID int) error {
+	_, err := m.DB.Exec(`DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`, userID, snippetID)
+	return err
+}
+
+func (m *StarModel) Starred(userID, snippetID int) (bool, error) {
+	var starred bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM stars WHERE user_id = ? AND snippet_id = ?)`
+	err := m.DB.QueryRow(stmt, userID, snippetID).Scan(&starred)
+	return starred, err
+}
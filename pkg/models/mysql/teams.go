@@ -0,0 +1,371 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"dsolerh/snippetbox/pkg/models"
+)
+
+// TeamModel stores the teams, their members and the invitations to join
+// them. Whether the user making a change is allowed to is up to the caller,
+// except for the invitations, which can only be answered with the email they
+// were sent to.
+type TeamModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new team, with a random slug like the snippets, and makes the
+// user creating it its owner.
+func (m *TeamModel) Insert(t *models.Team, ownerID int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO teams (slug, name, created) VALUES (?, ?, UTC_TIMESTAMP())`
+
+	var result sql.Result
+	var slug string
+	for i := 0; i < slugAttempts; i++ {
+		slug, err = randomSlug(slugLength)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		result, err = tx.Exec(stmt, slug, t.Name)
+		if !isDuplicate(err, "teams_uc_slug") {
+			break
+		}
+	}
+	if isDuplicate(err, "teams_uc_slug") {
+		tx.Rollback()
+		return 0, models.ErrDuplicateSlug
+	} else if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	stmt = `INSERT INTO team_members (team_id, user_id, role, joined) VALUES (?, ?, ?, UTC_TIMESTAMP())`
+	_, err = tx.Exec(stmt, id, ownerID, models.TeamRoleOwner)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	t.ID, t.Slug = int(id), slug
+	return t.ID, nil
+}
+
+func (m *TeamModel) GetBySlug(slug string) (*models.Team, error) {
+	t := &models.Team{}
+	stmt := `SELECT id, slug, name, created FROM teams WHERE slug = ?`
+	err := m.DB.QueryRow(stmt, slug).Scan(&t.ID, &t.Slug, &t.Name, &t.Created)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ForUser returns the teams the user is a member of, by name.
+func (m *TeamModel) ForUser(userID int) ([]*models.Team, error) {
+	stmt := `SELECT t.id, t.slug, t.name, t.created FROM team_members tm
+	JOIN teams t ON t.id = tm.team_id
+	WHERE tm.user_id = ? ORDER BY t.name, t.id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*models.Team{}
+	for rows.Next() {
+		t := &models.Team{}
+		if err = rows.Scan(&t.ID, &t.Slug, &t.Name, &t.Created); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// Role returns the role of the user in the team. If they aren't a member of
+// it we return the ErrNoRecord error.
+func (m *TeamModel) Role(teamID, userID int) (string, error) {
+	var role string
+	stmt := `SELECT role FROM team_members WHERE team_id = ? AND user_id = ?`
+	err := m.DB.QueryRow(stmt, teamID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", models.ErrNoRecord
+	} else if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// Members returns the members of the team, owners first.
+func (m *TeamModel) Members(teamID int) ([]*models.TeamMember, error) {
+	stmt := `SELECT tm.team_id, tm.user_id, u.name, u.email, tm.role, tm.joined FROM team_members tm
+	JOIN users u ON u.id = tm.user_id
+	WHERE tm.team_id = ? ORDER BY tm.role = 'owner' DESC, u.name, u.id`
+
+	rows, err := m.DB.Query(stmt, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*models.TeamMember{}
+	for rows.Next() {
+		tm := &models.TeamMember{}
+		err = rows.Scan(&tm.TeamID, &tm.UserID, &tm.Name, &tm.Email, &tm.Role, &tm.Joined)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, tm)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// SetRole changes the role of a member of the team. The last owner of a team
+// can't become a simple member, we return the ErrLastOwner error instead.
+func (m *TeamModel) SetRole(teamID, userID int, role string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if role != models.TeamRoleOwner {
+		if err = m.checkOtherOwner(tx, teamID, userID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	stmt := `UPDATE team_members SET role = ? WHERE team_id = ? AND user_id = ?`
+	if _, err = tx.Exec(stmt, role, teamID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMember takes the user out of the team, whether they leave or are
+// removed by an owner. The snippets they created for the team stay with it.
+// The last owner of a team can't leave it, we return the ErrLastOwner error.
+func (m *TeamModel) RemoveMember(teamID, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = m.checkOtherOwner(tx, teamID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = expectOneRow(result); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkOtherOwner returns the ErrLastOwner error if the user is the only
+// owner of the team. The owners are locked until the end of the transaction,
+// so two owners can't demote each other at the same time.
+func (m *TeamModel) checkOtherOwner(tx *sql.Tx, teamID, userID int) error {
+	rows, err := tx.Query(`SELECT user_id FROM team_members WHERE team_id = ? AND role = ? FOR UPDATE`, teamID, models.TeamRoleOwner)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	others := 0
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return err
+		}
+		if id != userID {
+			others++
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if others == 0 {
+		return models.ErrLastOwner
+	}
+	return nil
+}
+
+// Invite invites whoever has the email to join the team and returns the
+// random token they need to accept the invitation. Only a hash of the token
+// is stored, like for the sessions. Inviting the same email twice replaces the
+// token, and if a member of the team already has it we return the
+// ErrAlreadyMember error.
+func (m *TeamModel) Invite(teamID int, email string, invitedBy int) (string, error) {
+	var member bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM team_members tm JOIN users u ON u.id = tm.user_id
+	WHERE tm.team_id = ? AND u.email = ?)`
+	if err := m.DB.QueryRow(stmt, teamID, email).Scan(&member); err != nil {
+		return "", err
+	}
+	if member {
+		return "", models.ErrAlreadyMember
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	stmt = `INSERT INTO team_invitations (team_id, email, token_hash, invited_by, created)
+	VALUES (?, ?, ?, ?, UTC_TIMESTAMP())
+	ON DUPLICATE KEY UPDATE token_hash = VALUES(token_hash), invited_by = VALUES(invited_by), created = VALUES(created)`
+	_, err = m.DB.Exec(stmt, teamID, email, hashToken(token), invitedBy)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Invitation returns the invitation with the token.
+func (m *TeamModel) Invitation(token string) (*models.Invitation, error) {
+	stmt := `SELECT i.id, i.team_id, t.name, i.email, i.invited_by, i.created FROM team_invitations i
+	JOIN teams t ON t.id = i.team_id
+	WHERE i.token_hash = ?`
+
+	i := &models.Invitation{}
+	err := m.DB.QueryRow(stmt, hashToken(token)).Scan(&i.ID, &i.TeamID, &i.TeamName, &i.Email, &i.InvitedBy, &i.Created)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Accept makes the user a member of the team of the invitation with the
+// token, which can only be used once. If there is no such invitation we
+// return the ErrNoRecord error.
+func (m *TeamModel) Accept(token string, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	var id, teamID int
+	stmt := `SELECT id, team_id FROM team_invitations WHERE token_hash = ? FOR UPDATE`
+	err = tx.QueryRow(stmt, hashToken(token)).Scan(&id, &teamID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.ErrNoRecord
+	} else if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt = `INSERT IGNORE INTO team_members (team_id, user_id, role, joined) VALUES (?, ?, ?, UTC_TIMESTAMP())`
+	if _, err = tx.Exec(stmt, teamID, userID, models.TeamRoleMember); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM team_invitations WHERE id = ?`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Decline deletes the invitation with the token.
+func (m *TeamModel) Decline(token string) error {
+	result, err := m.DB.Exec(`DELETE FROM team_invitations WHERE token_hash = ?`, hashToken(token))
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// soleTeams returns the ids of the teams the user is the only member of.
+func soleTeams(tx *sql.Tx, userID int) ([]int, error) {
+	stmt := `SELECT team_id FROM team_members WHERE team_id IN (SELECT team_id FROM team_members WHERE user_id = ?)
+	GROUP BY team_id HAVING COUNT(*) = 1`
+	rows, err := tx.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// deleteTeam removes the team with its members, its invitations and its
+// snippets, like SnippetModel.Delete removes them. Their attachments are left
+// to be removed with the other orphans.
+func deleteTeam(tx *sql.Tx, id int) error {
+	teamSnippets := `SELECT id FROM snippets WHERE team_id = ?`
+
+	if err := releaseBodies(tx, `snippet_id IN (`+teamSnippets+`)`, id); err != nil {
+		return err
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM comments WHERE snippet_id IN (` + teamSnippets + `)`,
+		`DELETE FROM stars WHERE snippet_id IN (` + teamSnippets + `)`,
+		`DELETE FROM collection_snippets WHERE snippet_id IN (` + teamSnippets + `)`,
+		`DELETE FROM snippet_files WHERE snippet_id IN (` + teamSnippets + `)`,
+		`DELETE FROM snippets WHERE team_id = ?`,
+		`DELETE FROM team_invitations WHERE team_id = ?`,
+		`DELETE FROM team_members WHERE team_id = ?`,
+		`DELETE FROM teams WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
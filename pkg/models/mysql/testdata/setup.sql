@@ -1,19 +1,55 @@
 DROP TABLE IF EXISTS snippets;
 CREATE TABLE snippets (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  slug VARCHAR(16),
+  user_id INTEGER NOT NULL,
   title VARCHAR(100) NOT NULL,
-  content TEXT NOT NULL,
   created DATETIME NOT NULL,
-  expires DATETIME NOT NULL
+  expires DATETIME,
+  visibility VARCHAR(8) NOT NULL DEFAULT 'public',
+  content_type VARCHAR(16) NOT NULL DEFAULT 'text',
+  burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+  hashed_password CHAR(60) NOT NULL DEFAULT '',
+  key_id VARCHAR(16) NOT NULL DEFAULT '',
+  forked_from INTEGER,
+  team_id INTEGER
 );
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
 CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+CREATE INDEX idx_snippets_visibility_created ON snippets(visibility, created);
+CREATE INDEX idx_snippets_forked_from ON snippets(forked_from);
+CREATE INDEX idx_snippets_team_id ON snippets(team_id);
+CREATE INDEX idx_snippets_expires ON snippets(expires);
+DROP TABLE IF EXISTS snippet_files;
+CREATE TABLE snippet_files (
+  snippet_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  language VARCHAR(32) NOT NULL DEFAULT '',
+  body_hash CHAR(64) NOT NULL,
+  PRIMARY KEY (snippet_id, position)
+);
+DROP TABLE IF EXISTS snippet_bodies;
+CREATE TABLE snippet_bodies (
+  hash CHAR(64) NOT NULL PRIMARY KEY,
+  content MEDIUMBLOB NOT NULL,
+  encoding VARCHAR(8) NOT NULL DEFAULT '',
+  size INTEGER NOT NULL DEFAULT 0,
+  refs INTEGER NOT NULL
+);
 DROP TABLE IF EXISTS users;
 CREATE TABLE users (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
   name VARCHAR(255) NOT NULL,
   email VARCHAR(255) NOT NULL,
   hashed_password CHAR(60) NOT NULL,
-  created DATETIME NOT NULL
+  created DATETIME NOT NULL,
+  totp_secret VARCHAR(64) NOT NULL DEFAULT '',
+  totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  totp_last_step BIGINT NOT NULL DEFAULT 0,
+  role VARCHAR(16) NOT NULL DEFAULT 'user',
+  suspended BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
@@ -23,4 +59,142 @@ INSERT INTO users (name, email, hashed_password, created) VALUES (
   'alice@example.com',
   '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
   '2018-12-23 17:25:22'
+);
+
+DROP TABLE IF EXISTS recovery_codes;
+CREATE TABLE recovery_codes (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  hashed_code CHAR(64) NOT NULL
+);
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+DROP TABLE IF EXISTS sessions;
+CREATE TABLE sessions (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  token_hash CHAR(64) NOT NULL,
+  user_id INTEGER NOT NULL,
+  ip VARCHAR(45) NOT NULL,
+  user_agent VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL,
+  last_seen DATETIME NOT NULL,
+  expires DATETIME NOT NULL
+);
+ALTER TABLE sessions ADD CONSTRAINT sessions_uc_token_hash UNIQUE (token_hash);
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_expires ON sessions(expires);
+
+DROP TABLE IF EXISTS comments;
+CREATE TABLE comments (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  parent_id INTEGER,
+  file INTEGER NOT NULL DEFAULT 0,
+  line INTEGER NOT NULL DEFAULT 0,
+  content TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  updated DATETIME,
+  deleted BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX idx_comments_snippet_id ON comments(snippet_id, created);
+CREATE INDEX idx_comments_user_id ON comments(user_id);
+
+DROP TABLE IF EXISTS stars;
+CREATE TABLE stars (
+  user_id INTEGER NOT NULL,
+  snippet_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (user_id, snippet_id)
+);
+CREATE INDEX idx_stars_snippet_id ON stars(snippet_id);
+
+DROP TABLE IF EXISTS collections;
+CREATE TABLE collections (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  slug VARCHAR(16) NOT NULL,
+  user_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  public BOOLEAN NOT NULL DEFAULT FALSE,
+  created DATETIME NOT NULL
+);
+ALTER TABLE collections ADD CONSTRAINT collections_uc_slug UNIQUE (slug);
+CREATE INDEX idx_collections_user_id ON collections(user_id);
+
+DROP TABLE IF EXISTS collection_snippets;
+CREATE TABLE collection_snippets (
+  collection_id INTEGER NOT NULL,
+  snippet_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  PRIMARY KEY (collection_id, snippet_id)
+);
+CREATE INDEX idx_collection_snippets_snippet_id ON collection_snippets(snippet_id);
+
+DROP TABLE IF EXISTS teams;
+CREATE TABLE teams (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  slug VARCHAR(16) NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  created DATETIME NOT NULL
+);
+ALTER TABLE teams ADD CONSTRAINT teams_uc_slug UNIQUE (slug);
+
+DROP TABLE IF EXISTS team_members;
+CREATE TABLE team_members (
+  team_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  role VARCHAR(16) NOT NULL DEFAULT 'member',
+  joined DATETIME NOT NULL,
+  PRIMARY KEY (team_id, user_id)
+);
+CREATE INDEX idx_team_members_user_id ON team_members(user_id);
+
+DROP TABLE IF EXISTS team_invitations;
+CREATE TABLE team_invitations (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  team_id INTEGER NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  invited_by INTEGER NOT NULL,
+  created DATETIME NOT NULL
+);
+ALTER TABLE team_invitations ADD CONSTRAINT team_invitations_uc_team_id_email UNIQUE (team_id, email);
+ALTER TABLE team_invitations ADD CONSTRAINT team_invitations_uc_token_hash UNIQUE (token_hash);
+CREATE INDEX idx_team_invitations_email ON team_invitations(email);
+
+DROP TABLE IF EXISTS attachments;
+CREATE TABLE attachments (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  content_type VARCHAR(100) NOT NULL,
+  size BIGINT NOT NULL,
+  blob_key VARCHAR(64) NOT NULL,
+  created DATETIME NOT NULL
+);
+CREATE INDEX idx_attachments_snippet_id ON attachments(snippet_id);
+
+INSERT INTO snippets (slug, user_id, title, created, expires, visibility, burn_after_reading) VALUES (
+  'burnme',
+  1,
+  'A secret',
+  '2018-12-23 17:25:22',
+  '2099-12-31 23:59:59',
+  'unlisted',
+  TRUE
+);
+
+INSERT INTO snippet_files (snippet_id, position, name, language, body_hash) VALUES (
+  1,
+  0,
+  'secret.txt',
+  '',
+  'd13e46b0defc80c82880e96c4f766dfcfd762680106c57594de146a2fdf6fefc'
+);
+
+INSERT INTO snippet_bodies (hash, content, size, refs) VALUES (
+  'd13e46b0defc80c82880e96c4f766dfcfd762680106c57594de146a2fdf6fefc',
+  'This message will self-destruct...',
+  34,
+  1
 );
\ No newline at end of file
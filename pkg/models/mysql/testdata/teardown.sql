@@ -1,3 +1,27 @@
+DROP TABLE attachments;
+
+DROP TABLE team_invitations;
+
+DROP TABLE team_members;
+
+DROP TABLE teams;
+
+DROP TABLE collection_snippets;
+
+DROP TABLE collections;
+
+DROP TABLE stars;
+
+DROP TABLE comments;
+
+DROP TABLE sessions;
+
+DROP TABLE recovery_codes;
+
 DROP TABLE users;
 
+DROP TABLE snippet_bodies;
+
+DROP TABLE snippet_files;
+
 DROP TABLE snippets;
\ No newline at end of file
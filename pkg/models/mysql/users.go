@@ -1,11 +1,12 @@
 package mysql
 
 import (
+	"crypto/sha256"
 	"database/sql"
 	"dsolerh/snippetbox/pkg/models"
+	"encoding/hex"
 	"strings"
 
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -13,6 +14,18 @@ type UserModel struct {
 	DB *sql.DB
 }
 
+// the columns read into a models.User, in the order expected by scanUser
+const userColumns = `id, name, email, created, totp_enabled, role, suspended`
+
+func scanUser(row scanner) (*models.User, error) {
+	u := &models.User{}
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.TOTPEnabled, &u.Role, &u.Suspended)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
 func (m *UserModel) Insert(name, email, password string) error {
 	// create a hash of the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
@@ -24,12 +37,8 @@ func (m *UserModel) Insert(name, email, password string) error {
 	VALUES (?, ?, ?, UTC_TIMESTAMP())`
 
 	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
-	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			if mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, "users_uc_email") {
-				return models.ErrDuplicateEmail
-			}
-		}
+	if isDuplicate(err, "users_uc_email") {
+		return models.ErrDuplicateEmail
 	}
 	return err
 }
@@ -39,8 +48,9 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	// If no matching email exist, we return the ErrInvalidCredentials error.
 	var id int
 	var hashedPassword []byte
-	row := m.DB.QueryRow("SELECT id, hashed_password FROM users WHERE email = ?", email)
-	err := row.Scan(&id, &hashedPassword)
+	var suspended bool
+	row := m.DB.QueryRow("SELECT id, hashed_password, suspended FROM users WHERE email = ?", email)
+	err := row.Scan(&id, &hashedPassword, &suspended)
 	if err == sql.ErrNoRows {
 		return 0, models.ErrInvalidCredentials
 	} else if err != nil {
@@ -56,15 +66,30 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 		return 0, err
 	}
 
+	// The password is correct but suspended users still can't log in.
+	if suspended {
+		return 0, models.ErrSuspended
+	}
+
 	// The password is correct so, return the id
 	return id, nil
 }
 
 func (m *UserModel) Get(id int) (*models.User, error) {
-	user := &models.User{}
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	user, err := scanUser(m.DB.QueryRow(stmt, id))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
 
-	stmt := `SELECT id, name, email, created FROM users WHERE id = ?`
-	err := m.DB.QueryRow(stmt, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created)
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE email = ?`
+	user, err := scanUser(m.DB.QueryRow(stmt, email))
 	if err == sql.ErrNoRows {
 		return nil, models.ErrNoRecord
 	}
@@ -73,3 +98,311 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	}
 	return user, nil
 }
+
+// List returns a page of the users matching the filter, ordered by id, along
+// with the total number of matching users.
+func (m *UserModel) List(f models.UserFilter) ([]*models.User, int, error) {
+	where := `WHERE (? = '' OR name LIKE ? OR email LIKE ?) AND (? = '' OR role = ?)`
+	pattern := likePattern(f.Search)
+	args := []interface{}{f.Search, pattern, pattern, f.Role, f.Role}
+
+	var total int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM users `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT ` + userColumns + ` FROM users ` + where + ` ORDER BY id LIMIT ? OFFSET ?`
+	rows, err := m.DB.Query(stmt, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (m *UserModel) Stats() (*models.UserStats, error) {
+	stmt := `SELECT COUNT(*), COALESCE(SUM(suspended), 0), COALESCE(SUM(role = ?), 0) FROM users`
+
+	s := &models.UserStats{}
+	err := m.DB.QueryRow(stmt, models.RoleAdmin).Scan(&s.Total, &s.Suspended, &s.Admins)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *UserModel) SetRole(id int, role string) error {
+	result, err := m.DB.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// MySQL doesn't count rows which already had the role as affected,
+		// so check whether the user exists at all.
+		_, err = m.Get(id)
+		return err
+	}
+	return nil
+}
+
+// SetSuspended suspends or reinstates the user. Suspending also ends all the
+// sessions of the user so they are logged out straight away.
+func (m *UserModel) SetSuspended(id int, suspended bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET suspended = ? WHERE id = ?`, suspended, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if suspended {
+		_, err = tx.Exec(`DELETE FROM sessions WHERE user_id = ?`, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the user with the given id together with every snippet they
+// own and every comment which was made on their snippets. The comments they
+// wrote elsewhere are deleted like CommentModel.Delete does, and no longer
+// tell who wrote them, so the replies to them keep their place. The snippets
+// they created for a team stay with the team, unless they were its only
+// member: the team goes with them then. Everything happens in
+// a single transaction so a failure never leaves behind a partially deleted
+// account. Like the last owner of a team can't leave it, the last owner of a
+// team with other members can't delete their account, we return the
+// ErrLastOwner error instead.
+func (m *UserModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	// The owners of the teams are locked until the end of the transaction,
+	// like checkOtherOwner does, so the last two owners can't both go.
+	stmt := `SELECT COUNT(*) FROM team_members mine
+	WHERE mine.user_id = ? AND mine.role = ?
+	AND EXISTS (SELECT 1 FROM team_members others WHERE others.team_id = mine.team_id AND others.user_id <> mine.user_id)
+	AND NOT EXISTS (SELECT 1 FROM team_members owners WHERE owners.team_id = mine.team_id AND owners.user_id <> mine.user_id AND owners.role = ?)
+	FOR UPDATE`
+	var lastOwner int
+	if err = tx.QueryRow(stmt, id, models.TeamRoleOwner, models.TeamRoleOwner).Scan(&lastOwner); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if lastOwner > 0 {
+		tx.Rollback()
+		return models.ErrLastOwner
+	}
+
+	teamIDs, err := soleTeams(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, teamID := range teamIDs {
+		if err = deleteTeam(tx, teamID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// The snippets created for a team belong to the team, they are kept.
+	ownSnippets := `SELECT id FROM snippets WHERE user_id = ? AND team_id IS NULL`
+
+	if err = releaseBodies(tx, `snippet_id IN (`+ownSnippets+`)`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM comments WHERE snippet_id IN (` + ownSnippets + `)`,
+		`UPDATE comments SET content = '', deleted = TRUE, user_id = 0 WHERE user_id = ?`,
+		`DELETE FROM stars WHERE user_id = ?`,
+		`DELETE FROM stars WHERE snippet_id IN (` + ownSnippets + `)`,
+		`DELETE FROM collection_snippets WHERE collection_id IN (SELECT id FROM collections WHERE user_id = ?)`,
+		`DELETE FROM collection_snippets WHERE snippet_id IN (` + ownSnippets + `)`,
+		`DELETE FROM snippet_files WHERE snippet_id IN (` + ownSnippets + `)`,
+		`DELETE FROM collections WHERE user_id = ?`,
+		`DELETE FROM snippets WHERE user_id = ? AND team_id IS NULL`,
+		`DELETE FROM team_members WHERE user_id = ?`,
+		`DELETE FROM team_invitations WHERE invited_by = ?`,
+		`DELETE FROM team_invitations WHERE email = (SELECT email FROM users WHERE id = ?)`,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+		`DELETE FROM sessions WHERE user_id = ?`,
+	} {
+		_, err = tx.Exec(stmt, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n == 0 {
+		tx.Rollback()
+		return models.ErrNoRecord
+	}
+
+	return tx.Commit()
+}
+
+// SetTOTPSecret stores a new, not yet confirmed, TOTP secret for the user.
+// Two-factor authentication stays disabled until EnableTOTP is called.
+func (m *UserModel) SetTOTPSecret(id int, secret string) error {
+	stmt := `UPDATE users SET totp_secret = ?, totp_enabled = FALSE WHERE id = ?`
+	_, err := m.DB.Exec(stmt, secret, id)
+	return err
+}
+
+func (m *UserModel) TOTPSecret(id int) (string, error) {
+	var secret string
+	err := m.DB.QueryRow(`SELECT totp_secret FROM users WHERE id = ?`, id).Scan(&secret)
+	if err == sql.ErrNoRows {
+		return "", models.ErrNoRecord
+	} else if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// EnableTOTP turns on two-factor authentication for the user and replaces
+// their recovery codes. Only the hashes of the codes are stored.
+func (m *UserModel) EnableTOTP(id int, recoveryCodes []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET totp_enabled = TRUE WHERE id = ? AND totp_secret <> ''`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, code := range recoveryCodes {
+		_, err = tx.Exec(`INSERT INTO recovery_codes (user_id, hashed_code) VALUES (?, ?)`, id, hashRecoveryCode(code))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *UserModel) DisableTOTP(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET totp_secret = '', totp_enabled = FALSE WHERE id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes. Deleting the row
+// is what checks the code, so the same code can never be used twice, even by
+// concurrent requests. If the code doesn't match we return the
+// ErrInvalidCredentials error.
+func (m *UserModel) UseRecoveryCode(id int, code string) error {
+	stmt := `DELETE FROM recovery_codes WHERE user_id = ? AND hashed_code = ?`
+	result, err := m.DB.Exec(stmt, id, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// UseTOTPStep records that the user logged in with the code of the given TOTP
+// step. Only a step after the last one used is accepted, checked and stored in
+// a single statement so concurrent requests can't both use it. If the step
+// has already been used we return the ErrInvalidCredentials error.
+func (m *UserModel) UseTOTPStep(id int, step int64) error {
+	stmt := `UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`
+	result, err := m.DB.Exec(stmt, step, id, step)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// Recovery codes are long random strings, so a plain SHA-256 is enough to
+// protect them at rest. They are normalized first so that the dash and the
+// letter case don't matter when the user types them in.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -29,6 +29,7 @@ func TestUserModelGet(t *testing.T) {
 				Name:    "Alice Jones",
 				Email:   "alice@example.com",
 				Created: time.Date(2018, 12, 23, 17, 25, 22, 0, time.UTC),
+				Role:    models.RoleUser,
 			},
 			wantError: nil,
 		},
@@ -68,3 +69,137 @@ func TestUserModelGet(t *testing.T) {
 		})
 	}
 }
+
+func TestUserModelDeleteLastOwner(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	users := UserModel{DB: db}
+	teams := TeamModel{DB: db}
+
+	if err := users.Insert("Bob", "bob@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	teamID, err := teams.Insert(&models.Team{Name: "Developers"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO team_members (team_id, user_id, role, joined) VALUES (?, 2, ?, UTC_TIMESTAMP())`, teamID, models.TeamRoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = users.Delete(1); err != models.ErrLastOwner {
+		t.Errorf("want %v; got %v", models.ErrLastOwner, err)
+	}
+
+	// Once Bob owns the team too, Alice can go.
+	if err = teams.SetRole(teamID, 2, models.TeamRoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if err = users.Delete(1); err != nil {
+		t.Errorf("want the user to be deleted; got %v", err)
+	}
+}
+
+func TestUserModelDelete(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	users := UserModel{DB: db}
+	teams := TeamModel{DB: db}
+	comments := CommentModel{DB: db}
+	snippets := SnippetModel{DB: db}
+
+	// Bob comments on the snippet of Alice, who answers, he has a team of his
+	// own with a snippet and Alice invited him to hers.
+	if err := users.Insert("Bob", "bob@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	commentID, err := comments.Insert(&models.Comment{SnippetID: 1, UserID: 2, Content: "Lovely"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyID, err := comments.Insert(&models.Comment{SnippetID: 1, UserID: 1, ParentID: commentID, Content: "Thanks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	soloID, err := teams.Insert(&models.Team{Name: "Solo"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	teamSnippet := &models.Snippet{
+		UserID:      2,
+		Title:       "Alone",
+		Visibility:  models.VisibilityPublic,
+		ContentType: models.ContentTypeText,
+		TeamID:      soloID,
+		Files:       []*models.File{{Name: "alone.txt", Content: "Nobody else here"}},
+	}
+	if _, err = snippets.Insert(teamSnippet); err != nil {
+		t.Fatal(err)
+	}
+	devsID, err := teams.Insert(&models.Team{Name: "Developers"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = teams.Invite(devsID, "bob@example.com", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = users.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Invitations to the email", func(t *testing.T) {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM team_invitations WHERE email = ?`, "bob@example.com").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("want the invitations to the user to be deleted; got %d", n)
+		}
+	})
+
+	t.Run("Comments", func(t *testing.T) {
+		thread, err := comments.ForSnippet(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byID := map[int]*models.Comment{}
+		for _, c := range thread {
+			byID[c.ID] = c
+		}
+		c, reply := byID[commentID], byID[replyID]
+		if c == nil || reply == nil {
+			t.Fatalf("want the comment and its reply to be kept; got %d comments", len(thread))
+		}
+		if !c.Deleted || c.Content != "" || c.UserID != 0 || c.UserName != "" {
+			t.Errorf("want the comment deleted without its author; got %+v", c)
+		}
+		if reply.ParentID != commentID {
+			t.Errorf("want the reply to keep its parent; got %d", reply.ParentID)
+		}
+	})
+
+	t.Run("Team of the user alone", func(t *testing.T) {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM teams WHERE id = ?`, soloID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("want the team to be deleted")
+		}
+		if _, err := snippets.GetBySlug(teamSnippet.Slug, 0); err != models.ErrNoRecord {
+			t.Errorf("want the snippets of the team to be deleted; got %v", err)
+		}
+	})
+}
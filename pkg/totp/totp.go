@@ -0,0 +1,141 @@
+// Package totp implements RFC 6238 time-based one-time passwords using only
+// the standard library, so it can be tested without network access or third
+// party authenticators.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the length of the generated codes.
+	Digits = 6
+	// Period is the number of seconds a code stays valid.
+	Period = 30
+	// Skew is the number of periods before and after the current one that
+	// are still accepted, to make up for clock drift on the user's device.
+	Skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random 160-bit secret encoded in base32, the
+// format expected by authenticator apps.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Code returns the one-time password for the given secret at time t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, counter(t)), nil
+}
+
+// Validate reports whether code is a valid one-time password for the given
+// secret at time t, allowing for Skew periods of clock drift.
+func Validate(secret, code string, t time.Time) bool {
+	_, ok := ValidateStep(secret, code, t)
+	return ok
+}
+
+// ValidateStep is like Validate but also returns the period the code belongs
+// to, counted since the Unix epoch. Remembering the last step accepted for a
+// user is what stops a code from being used twice.
+func ValidateStep(secret, code string, t time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	c := int64(counter(t))
+	for i := -Skew; i <= Skew; i++ {
+		want := hotp(key, uint64(c+int64(i)))
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return c + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+// URI returns the otpauth:// URI used to enrol the secret in an
+// authenticator app, either by following it or by encoding it in a QR code.
+func URI(secret, issuer, account string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(Period))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// GenerateRecoveryCodes returns n random single-use codes formatted as two
+// groups of five characters, e.g. "k3j9a-x0pqe".
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes, nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return encoding.DecodeString(strings.TrimRight(secret, "="))
+}
+
+func counter(t time.Time) uint64 {
+	return uint64(t.Unix() / Period)
+}
+
+// hotp implements the RFC 4226 HMAC-based one-time password algorithm.
+func hotp(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, see RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
@@ -0,0 +1,110 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA1 test vectors from RFC 6238 appendix B, truncated to 6 digits.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	testCases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tC := range testCases {
+		got, err := Code(rfcSecret, time.Unix(tC.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tC.want {
+			t.Errorf("at %d: want %q; got %q", tC.unix, tC.want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	testCases := []struct {
+		desc   string
+		secret string
+		code   string
+		want   bool
+	}{
+		{"Current period", rfcSecret, "050471", true},
+		{"Previous period", rfcSecret, "081804", true},
+		{"Surrounding spaces", rfcSecret, " 050471 ", true},
+		{"Wrong code", rfcSecret, "123456", false},
+		{"Too short", rfcSecret, "05047", false},
+		{"Empty", rfcSecret, "", false},
+		{"Invalid secret", "not base32!", "050471", false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			if got := Validate(tC.secret, tC.code, now); got != tC.want {
+				t.Errorf("want %v; got %v", tC.want, got)
+			}
+		})
+	}
+
+	if Validate(rfcSecret, "050471", now.Add(5*Period*time.Second)) {
+		t.Error("want a code to expire outside the allowed skew")
+	}
+
+	step, ok := ValidateStep(rfcSecret, "081804", now)
+	if !ok || step != now.Unix()/Period-1 {
+		t.Errorf("want the code of the previous period to be step %d; got %d", now.Unix()/Period-1, step)
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Validate(secret, code, time.Now()) {
+		t.Errorf("want the code generated for a new secret to validate")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("JBSWY3DPEHPK3PXP", "Snippetbox", "alice@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/Snippetbox:alice@example.com?") {
+		t.Errorf("unexpected uri prefix %q", uri)
+	}
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Errorf("want uri %q to contain the secret", uri)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' {
+			t.Errorf("malformed recovery code %q", c)
+		}
+		if seen[c] {
+			t.Errorf("duplicate recovery code %q", c)
+		}
+		seen[c] = true
+	}
+}
@@ -0,0 +1,156 @@
+// End-to-end encryption of snippets. The content of every file is encrypted
+// with AES-GCM, using the same key, before it leaves the browser and the key
+// is only ever kept in the fragment of the link (the part after the #), which
+// browsers never send to the server.
+(function () {
+	if (!window.crypto || !window.crypto.subtle) {
+		return;
+	}
+
+	var NONCE_LENGTH = 12;
+
+	function toBase64(bytes) {
+		var s = "";
+		for (var i = 0; i < bytes.length; i++) {
+			s += String.fromCharCode(bytes[i]);
+		}
+		return btoa(s);
+	}
+
+	function fromBase64(s) {
+		var bin = atob(s);
+		var bytes = new Uint8Array(bin.length);
+		for (var i = 0; i < bin.length; i++) {
+			bytes[i] = bin.charCodeAt(i);
+		}
+		return bytes;
+	}
+
+	function toBase64URL(bytes) {
+		return toBase64(bytes).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+	}
+
+	function fromBase64URL(s) {
+		s = s.replace(/-/g, "+").replace(/_/g, "/");
+		while (s.length % 4) {
+			s += "=";
+		}
+		return fromBase64(s);
+	}
+
+	// encrypt returns the base64 ciphertext of the text: a fresh nonce
+	// followed by the encrypted text.
+	function encrypt(text, key) {
+		var nonce = crypto.getRandomValues(new Uint8Array(NONCE_LENGTH));
+		return crypto.subtle.encrypt({name: "AES-GCM", iv: nonce}, key, new TextEncoder().encode(text))
+			.then(function (ct) {
+				ct = new Uint8Array(ct);
+				var payload = new Uint8Array(nonce.length + ct.length);
+				payload.set(nonce);
+				payload.set(ct, nonce.length);
+				return toBase64(payload);
+			});
+	}
+
+	// encryptAll encrypts the texts with a new key. It returns their base64
+	// ciphertexts, in the same order, and the base64url key needed to decrypt
+	// them.
+	function encryptAll(texts) {
+		var key;
+		return crypto.subtle.generateKey({name: "AES-GCM", length: 256}, true, ["encrypt"])
+			.then(function (k) {
+				key = k;
+				return Promise.all(texts.map(function (text) {
+					return encrypt(text, key);
+				}));
+			})
+			.then(function (ciphertexts) {
+				return crypto.subtle.exportKey("raw", key).then(function (raw) {
+					return {ciphertexts: ciphertexts, key: toBase64URL(new Uint8Array(raw))};
+				});
+			});
+	}
+
+	function decrypt(ciphertext, key) {
+		var payload = fromBase64(ciphertext);
+		return crypto.subtle.importKey("raw", fromBase64URL(key), {name: "AES-GCM"}, false, ["decrypt"])
+			.then(function (k) {
+				return crypto.subtle.decrypt({name: "AES-GCM", iv: payload.slice(0, NONCE_LENGTH)}, k, payload.slice(NONCE_LENGTH));
+			})
+			.then(function (plain) {
+				return new TextDecoder().decode(plain);
+			});
+	}
+
+	// On the create page, encrypt the files when asked to, post the form
+	// ourselves and add the key to the link of the new snippet.
+	var form = document.querySelector("form[data-encryptable]");
+	if (form) {
+		form.elements.encrypt.disabled = false;
+		form.addEventListener("submit", function (e) {
+			if (!form.elements.encrypt.checked) {
+				return;
+			}
+			e.preventDefault();
+
+			// Only the files are encrypted, the attachments would reach the server
+			// as they are.
+			if (form.elements.attachments && form.elements.attachments.files.length > 0) {
+				alert("Attachments can't be encrypted, remove them to encrypt the snippet.");
+				return;
+			}
+
+			var contents = form.querySelectorAll("textarea[name=file_content]");
+			var texts = Array.prototype.map.call(contents, function (c) {
+				return c.value;
+			});
+			encryptAll(texts)
+				.then(function (res) {
+					// The files are matched by the order of their fields, the
+					// contents can be put back after the names and languages.
+					var data = new URLSearchParams(new FormData(form));
+					data.delete("encrypt");
+					data.delete("attachments");
+					data.delete("file_content");
+					res.ciphertexts.forEach(function (ct) {
+						data.append("file_content", ct);
+					});
+					data.set("content_type", "encrypted");
+					return fetch(form.action, {method: "POST", body: data, credentials: "same-origin"})
+						.then(function (resp) {
+							if (resp.redirected) {
+								window.location = resp.url + "#" + res.key;
+								return;
+							}
+							// the form was rejected, show the errors
+							return resp.text().then(function (html) {
+								document.open();
+								document.write(html);
+								document.close();
+							});
+						});
+				})
+				.catch(function (err) {
+					alert("The snippet couldn't be encrypted: " + err.message);
+				});
+		});
+	}
+
+	// On the show page, decrypt the files with the key from the link. The
+	// results are only ever set as text, never as HTML.
+	var boxes = document.querySelectorAll("code.encrypted[data-ciphertext]");
+	var key = window.location.hash.slice(1);
+	Array.prototype.forEach.call(boxes, function (box) {
+		if (!key) {
+			box.textContent = "The key is missing from the link, this file can't be decrypted.";
+			return;
+		}
+		decrypt(box.getAttribute("data-ciphertext"), key)
+			.then(function (text) {
+				box.textContent = text;
+			})
+			.catch(function () {
+				box.textContent = "This file can't be decrypted, the key in the link is wrong.";
+			});
+	});
+})();
@@ -0,0 +1,49 @@
+// Adding and removing the files of a snippet in the create and edit forms.
+// Every file is a fieldset with the same inputs, so a new file is a copy of
+// the first one with its values cleared.
+(function () {
+	var files = document.querySelector("div.files");
+	if (!files) {
+		return;
+	}
+
+	var max = parseInt(files.getAttribute("data-max-files"), 10);
+	var add = files.querySelector("button.add-file");
+
+	function fieldsets() {
+		return files.querySelectorAll("fieldset.file");
+	}
+
+	// update only lets files be removed while there are more than one, and
+	// added while there are less than the maximum.
+	function update() {
+		var sets = fieldsets();
+		for (var i = 0; i < sets.length; i++) {
+			sets[i].querySelector("button.remove-file").hidden = sets.length < 2;
+		}
+		add.hidden = sets.length >= max;
+	}
+
+	files.addEventListener("click", function (e) {
+		if (e.target.classList.contains("remove-file")) {
+			e.target.parentNode.remove();
+			update();
+		}
+	});
+
+	add.addEventListener("click", function () {
+		var sets = fieldsets();
+		var file = sets[0].cloneNode(true);
+		var errors = file.querySelectorAll("label.error");
+		for (var i = 0; i < errors.length; i++) {
+			errors[i].remove();
+		}
+		file.querySelector("input[name=file_name]").value = "";
+		file.querySelector("select[name=file_language]").value = "";
+		file.querySelector("textarea[name=file_content]").value = "";
+		files.insertBefore(file, add);
+		update();
+	});
+
+	update();
+})();